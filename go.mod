@@ -3,14 +3,17 @@ module github.com/palavrapasse/import
 go 1.19
 
 require (
+	github.com/klauspost/compress v1.17.4
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/palavrapasse/aspirador v0.0.5
 	github.com/palavrapasse/damn v0.0.10
+	github.com/ulikunitz/xz v0.5.12
 	github.com/urfave/cli/v2 v2.24.4
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
-	github.com/mattn/go-sqlite3 v1.14.16 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
 )
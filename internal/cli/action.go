@@ -5,158 +5,135 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
-	"github.com/palavrapasse/damn/pkg/entity"
-	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
 	"github.com/palavrapasse/import/internal/logging"
 	"github.com/palavrapasse/import/internal/parser"
 	"github.com/urfave/cli/v2"
 )
 
-const MaxErrorLogCalls = 20000
-
 func CreateAction(databasePath *string, leakPath *string, context *string, platforms *cli.StringSlice,
-	shareDate *cli.Timestamp, leakers *cli.StringSlice, notifyNewLeakURL *string, skipInteractiveMode *bool,
-	storeImport func(databasePath string, i query.Import) (entity.AutoGenKey, error),
-	notifyImport func(entity.AutoGenKey, string) error,
+	shareDate *cli.Timestamp, leakers *cli.StringSlice, notifyTargets *cli.StringSlice,
+	notifierOptions *importer.NotifierOptions, skipInteractiveMode *bool,
+	format *string, parserOptions *parser.LeakParserOptions, rejectsPath *string,
+	errorThreshold *importer.ErrorThreshold, batchSize *int, force *bool,
+	storeImport importer.StoreImportFunc,
+	newNotifier importer.NewNotifierFunc,
+	markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
 ) func(cCtx *cli.Context) error {
 	return func(cCtx *cli.Context) error {
 		logging.Aspirador.Info("Starting Import")
 
 		var errors []error
 
-		err := validateNonEmptyValue(*leakPath, FlagLeakPath)
-		errors = appendValidError(errors, err)
-
-		err = validateNonEmptyValue(*databasePath, FlagDatabasePath)
+		err := validateNonEmptyValue(*databasePath, FlagDatabasePath)
 		errors = appendValidError(errors, err)
 
-		err = validateNonEmptyValue(*notifyNewLeakURL, FlagNotifyNewLeakURL)
+		notifiers, err := importer.NewNotifiers(notifyTargets.Value(), *notifierOptions, newNotifier)
 		errors = appendValidError(errors, err)
 
 		if len(errors) != 0 {
 			return errors[0]
 		}
 
-		var parser parser.LeakParser = parser.PlainTextLeakParser{
-			FilePath: *leakPath,
-		}
-
-		leakParse, errParse := parser.Parse()
-
-		if errParse != nil {
-			errorsCount := len(errParse)
-
-			if errorsCount > MaxErrorLogCalls {
-				logging.Aspirador.Warning(fmt.Sprintf("Found a lot of errors during leak parse (%d)...", errorsCount))
-			} else {
-				logging.Aspirador.Warning("Found the following errors parsing leak:")
+		leak := newLeak(*leakPath, *context, platforms, shareDate, leakers)
+		opts := newParseOptions(*format, *parserOptions, *rejectsPath, *errorThreshold)
 
-				for _, v := range errParse {
-					logging.Aspirador.Warning(v.Error())
-				}
-			}
+		lr, err := importer.Read(cCtx.Context, leak, opts)
 
-			if !*skipInteractiveMode {
-				fmt.Println("Proceed with import?")
-				reader := bufio.NewReader(os.Stdin)
-				input, _, errRead := reader.ReadLine()
-
-				if errRead != nil {
-					return errRead
-				}
-
-				if !IsProceedAnswer(proceedAnswers, strings.ToLower(string(input))) {
-					logging.Aspirador.Info("Stopped import")
-					return nil
-				}
-			}
-		}
-
-		platformsSlice := platforms.Value()
-		err = validateFlagValues(platformsSlice, FlagLeakPlatforms)
-		errors = appendValidError(errors, err)
-
-		leakersSlice := leakers.Value()
-		err = validateFlagValues(leakersSlice, FlagLeakers)
-		errors = appendValidError(errors, err)
-
-		leakPlatforms, err := createPlatforms(platformsSlice)
-		errors = appendValidError(errors, err)
-
-		leakBadActors, err := createBadActors(leakersSlice)
-		errors = appendValidError(errors, err)
-
-		shareDateFormat := shareDate.Value().Format(query.DateFormatLayout)
-		sharedatesc, err := query.NewDateInSeconds(shareDateFormat)
-		errors = appendValidError(errors, err)
-
-		if len(errors) != 0 {
-			return errors[0]
+		if err != nil {
+			return err
 		}
 
-		leak, err := query.NewLeak(*context, sharedatesc)
-		errors = appendValidError(errors, err)
+		proceed, err := confirmImport(lr, *skipInteractiveMode)
 
-		if len(errors) != 0 {
-			return errors[0]
+		if !proceed || err != nil {
+			return err
 		}
 
-		i := query.Import{
-			Leak:              leak,
-			AffectedUsers:     leakParse,
-			AffectedPlatforms: leakPlatforms,
-			Leakers:           leakBadActors,
+		im := importer.Importer{
+			DatabasePath:     *databasePath,
+			Notifiers:        notifiers,
+			Store:            storeImport,
+			MarkNotified:     markNotified,
+			LoadNotification: loadNotification,
+			NotifyHashes:     notifierOptions.Hashes,
+			OnProgress:       logStoreProgress,
+			BatchSize:        *batchSize,
+			Force:            *force,
 		}
 
-		leakId, errImport := storeImport(*databasePath, i)
+		_, err = im.Import(cCtx.Context, lr)
 
-		if errImport != nil {
-			return errImport
-		}
+		return err
+	}
+}
 
-		logging.Aspirador.Info(fmt.Sprintf("Successful Import (%d)", len(leakParse)))
+// Asks whether to proceed with the import of a leak that has parse errors, unless skipInteractiveMode is set.
+func confirmImport(lr importer.LeakRead, skipInteractiveMode bool) (bool, error) {
+	if lr.ErrorCount == 0 || skipInteractiveMode {
+		return true, nil
+	}
 
-		err = notifyImport(leakId, *notifyNewLeakURL)
+	fmt.Println("Proceed with import?")
+	reader := bufio.NewReader(os.Stdin)
+	input, _, errRead := reader.ReadLine()
 
-		if err != nil {
-			return err
-		}
+	if errRead != nil {
+		return false, errRead
+	}
 
-		return nil
+	if !IsProceedAnswer(proceedAnswers, strings.ToLower(string(input))) {
+		logging.Aspirador.Info("Stopped import")
+		return false, nil
 	}
-}
 
-func createPlatforms(platforms []string) ([]query.Platform, error) {
-	var list []query.Platform
+	return true, nil
+}
 
-	for _, v := range platforms {
-		platform, err := query.NewPlatform(v)
+// Logs how many users of a leak were stored after each batch, so that imports of large leaks are not silent.
+func logStoreProgress(p importer.Progress) {
+	if p.Phase != importer.StoringPhase || p.Users == 0 {
+		return
+	}
 
-		if err != nil {
-			return list, err
-		}
+	msg := fmt.Sprintf("Stored %d of %d users (%.1f%%)", p.Users, p.UsersTotal, 100*p.Fraction())
 
-		list = append(list, platform)
+	if eta := p.ETA(time.Now()).Round(time.Second); eta > 0 {
+		msg += fmt.Sprintf(", about %s left", eta)
 	}
 
-	return list, nil
+	logging.Aspirador.Info(msg)
 }
 
-func createBadActors(leakers []string) ([]query.BadActor, error) {
-	var list []query.BadActor
+func newLeak(leakPath string, context string, platforms *cli.StringSlice, shareDate *cli.Timestamp,
+	leakers *cli.StringSlice,
+) importer.Leak {
+	leak := importer.Leak{
+		Path:      leakPath,
+		Context:   context,
+		Platforms: platforms.Value(),
+		Leakers:   leakers.Value(),
+	}
 
-	for _, v := range leakers {
-		badActor, err := query.NewBadActor(v)
+	if shareDate.Value() != nil {
+		leak.ShareDate = *shareDate.Value()
+	}
 
-		if err != nil {
-			return list, err
-		}
+	return leak
+}
 
-		list = append(list, badActor)
+func newParseOptions(format string, parserOptions parser.LeakParserOptions, rejectsPath string,
+	errorThreshold importer.ErrorThreshold,
+) importer.ParseOptions {
+	return importer.ParseOptions{
+		Format:         format,
+		RejectsPath:    rejectsPath,
+		Parser:         parserOptions,
+		ErrorThreshold: errorThreshold,
 	}
-
-	return list, nil
 }
 
 func validateNonEmptyValue(value string, flag string) error {
@@ -167,14 +144,6 @@ func validateNonEmptyValue(value string, flag string) error {
 	return nil
 }
 
-func validateFlagValues(value []string, flag string) error {
-	if len(value) == 0 {
-		return fmt.Errorf("%s should not be empty", flag)
-	}
-
-	return nil
-}
-
 func appendValidError(errors []error, err error) []error {
 
 	if err != nil {
@@ -6,5 +6,33 @@ var AliasesFlagLeakContext = []string{"c"}
 var AliasesFlagLeakPlatforms = []string{"p"}
 var AliasesFlagLeakShareDate = []string{"sd"}
 var AliasesFlagLeakers = []string{"l"}
-var AliasesFlagNotifyNewLeakURL = []string{"notify-url"}
+var AliasesFlagNotify = []string{"notify-url"}
 var AliasesFlagSkipInteractiveMode = []string{"skip"}
+var AliasesFlagEmailColumn = []string{"ec"}
+var AliasesFlagPasswordColumn = []string{"pc"}
+var AliasesFlagEmailField = []string{"ef"}
+var AliasesFlagPasswordField = []string{"pf"}
+var AliasesFlagTable = []string{"t"}
+var AliasesFlagSQLDialect = []string{"sqld"}
+var AliasesFlagInclude = []string{"i"}
+var AliasesFlagFormat = []string{"f"}
+var AliasesFlagSeparator = []string{"sep"}
+var AliasesFlagSeparatorFallback = []string{"sepf"}
+var AliasesFlagRejectsPath = []string{"rp"}
+var AliasesFlagMaxErrors = []string{"me"}
+var AliasesFlagMaxErrorRatio = []string{"mer"}
+var AliasesFlagLimit = []string{"n"}
+var AliasesFlagShowPasswords = []string{"sp"}
+var AliasesFlagManifest = []string{"m"}
+var AliasesFlagContinueOnError = []string{"coe"}
+var AliasesFlagAddress = []string{"a"}
+var AliasesFlagJobsPath = []string{"jp"}
+var AliasesFlagWorkers = []string{"w"}
+var AliasesFlagBatchSize = []string{"bs"}
+var AliasesFlagForce = []string{"fo"}
+var AliasesFlagLeakId = []string{"id"}
+var AliasesFlagSkipNotification = []string{"sn"}
+var AliasesFlagPending = []string{"pe"}
+var AliasesFlagNotifySecret = []string{"ns"}
+var AliasesFlagNotifyHashes = []string{"nh"}
+var AliasesFlagMaxUploadSize = []string{"mus"}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"sort"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+	"github.com/palavrapasse/import/internal/parser"
+	"github.com/urfave/cli/v2"
+)
+
+const CommandAppend = "append"
+
+func CreateAppendCommand(storeImport importer.StoreImportFunc, newNotifier importer.NewNotifierFunc, markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
+) *cli.Command {
+	var leakId int64
+	var databasePath string
+	var leakPath string
+	var platforms cli.StringSlice
+	var leakers cli.StringSlice
+	var notifyTargets cli.StringSlice
+	var notifierOptions importer.NotifierOptions
+	var skipNotification bool
+	var skipInteractiveMode bool
+	var format string
+	var parserOptions parser.LeakParserOptions
+	var rejectsPath string
+	var errorThreshold importer.ErrorThreshold
+	var batchSize int
+	var force bool
+
+	flags := []cli.Flag{
+		&cli.Int64Flag{
+			Name:        FlagLeakId,
+			Aliases:     AliasesFlagLeakId,
+			Usage:       "Append to the stored leak with `ID`",
+			Required:    true,
+			Destination: &leakId,
+		},
+		&cli.PathFlag{
+			Name:        FlagDatabasePath,
+			Aliases:     AliasesFlagDatabasePath,
+			Usage:       "Store leaks into `SQLite Database`",
+			Required:    true,
+			Destination: &databasePath,
+		},
+		&cli.PathFlag{
+			Name:        FlagLeakPath,
+			Aliases:     AliasesFlagLeakPath,
+			Usage:       "Load another part of the leak from `FILE` (gzip, bzip2, xz, zstd, zip and tar files are supported)",
+			Required:    true,
+			Destination: &leakPath,
+		},
+		&cli.StringSliceFlag{
+			Name:        FlagLeakPlatforms,
+			Aliases:     AliasesFlagLeakPlatforms,
+			Usage:       "Other platforms affected by the leak (separated by commas)",
+			Required:    false,
+			Destination: &platforms,
+		},
+		&cli.StringSliceFlag{
+			Name:        FlagLeakers,
+			Aliases:     AliasesFlagLeakers,
+			Usage:       "Other leakers (separated by commas)",
+			Required:    false,
+			Destination: &leakers,
+		},
+		createNotifyFlag(&notifyTargets, "Notify `TARGET` of the new users of the leak"),
+		createNotifySecretFlag(&notifierOptions.Secret),
+		createNotifyHashesFlag(&notifierOptions.Hashes),
+		&cli.BoolFlag{
+			Name:        FlagSkipNotification,
+			Aliases:     AliasesFlagSkipNotification,
+			Usage:       "Whether to skip notifying the service of the new users of the leak",
+			Required:    false,
+			Value:       false,
+			Destination: &skipNotification,
+		},
+		&cli.BoolFlag{
+			Name:        FlagSkipInteractiveMode,
+			Aliases:     AliasesFlagSkipInteractiveMode,
+			Usage:       "Whether to skip questions the program might question you before taking any action",
+			Required:    false,
+			Value:       false,
+			Destination: &skipInteractiveMode,
+		},
+	}
+
+	flags = append(flags, createParserFlags(&format, &parserOptions)...)
+	flags = append(flags, createParseCheckFlags(&rejectsPath, &errorThreshold)...)
+	flags = append(flags, createStoreFlags(&batchSize, &force)...)
+
+	sort.Sort(cli.FlagsByName(flags))
+
+	return &cli.Command{
+		Name:   CommandAppend,
+		Usage:  "Appends the users, platforms and leakers of another part of a leak to the stored leak",
+		Flags:  flags,
+		Action: CreateAppendAction(&leakId, &databasePath, &leakPath, &platforms, &leakers, &notifyTargets, &notifierOptions, &skipNotification, &skipInteractiveMode, &format, &parserOptions, &rejectsPath, &errorThreshold, &batchSize, &force, storeImport, newNotifier, markNotified, loadNotification),
+	}
+}
+
+func CreateAppendAction(leakId *int64, databasePath *string, leakPath *string, platforms *cli.StringSlice,
+	leakers *cli.StringSlice, notifyTargets *cli.StringSlice, notifierOptions *importer.NotifierOptions,
+	skipNotification *bool, skipInteractiveMode *bool,
+	format *string, parserOptions *parser.LeakParserOptions, rejectsPath *string,
+	errorThreshold *importer.ErrorThreshold, batchSize *int, force *bool,
+	storeImport importer.StoreImportFunc,
+	newNotifier importer.NewNotifierFunc,
+	markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
+) func(cCtx *cli.Context) error {
+	return func(cCtx *cli.Context) error {
+		logging.Aspirador.Info("Starting Append")
+
+		notifiers, err := importer.NewNotifiers(notifyTargets.Value(), *notifierOptions, newNotifier)
+
+		if err != nil {
+			return err
+		}
+
+		leak := importer.Leak{
+			Path:      *leakPath,
+			Platforms: platforms.Value(),
+			Leakers:   leakers.Value(),
+			Id:        entity.AutoGenKey(*leakId),
+		}
+
+		opts := newParseOptions(*format, *parserOptions, *rejectsPath, *errorThreshold)
+
+		lr, err := importer.Read(cCtx.Context, leak, opts)
+
+		if err != nil {
+			return err
+		}
+
+		proceed, err := confirmImport(lr, *skipInteractiveMode)
+
+		if !proceed || err != nil {
+			return err
+		}
+
+		im := importer.Importer{
+			DatabasePath:     *databasePath,
+			Notifiers:        notifiers,
+			Store:            storeImport,
+			MarkNotified:     markNotified,
+			LoadNotification: loadNotification,
+			NotifyHashes:     notifierOptions.Hashes,
+			OnProgress:       logStoreProgress,
+			BatchSize:        *batchSize,
+			Force:            *force,
+			SkipNotify:       *skipNotification,
+		}
+
+		_, err = im.Import(cCtx.Context, lr)
+
+		return err
+	}
+}
@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+	"github.com/palavrapasse/import/internal/parser"
+	"github.com/urfave/cli/v2"
+)
+
+const CommandBatch = "batch"
+
+const (
+	BatchImported  = "imported"
+	BatchFailed    = "failed"
+	BatchSkipped   = "skipped"
+	BatchDuplicate = "duplicate"
+)
+
+// BatchResult is the outcome of importing a single leak of a manifest.
+type BatchResult struct {
+	Err      error
+	LeakPath string
+	Status   string
+	LeakId   entity.AutoGenKey
+	Users    int
+	Errors   int
+}
+
+func CreateBatchCommand(storeImport importer.StoreImportFunc, newNotifier importer.NewNotifierFunc, markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
+) *cli.Command {
+	var manifestPath string
+	var databasePath string
+	var notifyTargets cli.StringSlice
+	var notifierOptions importer.NotifierOptions
+	var continueOnError bool
+	var format string
+	var parserOptions parser.LeakParserOptions
+	var errorThreshold importer.ErrorThreshold
+	var batchSize int
+	var force bool
+
+	flags := []cli.Flag{
+		&cli.PathFlag{
+			Name:        FlagManifest,
+			Aliases:     AliasesFlagManifest,
+			Usage:       "Import every leak described in `FILE` (JSON or YAML, with the same shape as import-bash args.json)",
+			Required:    true,
+			Destination: &manifestPath,
+		},
+		&cli.PathFlag{
+			Name:        FlagDatabasePath,
+			Aliases:     AliasesFlagDatabasePath,
+			Usage:       "Store leaks into `SQLite Database` (overrides the manifest leaksdb_fp)",
+			Required:    false,
+			Destination: &databasePath,
+		},
+		createNotifyFlag(&notifyTargets, "Notify `TARGET` of each new leak, instead of the manifest notify_url and notify targets"),
+		createNotifySecretFlag(&notifierOptions.Secret),
+		createNotifyHashesFlag(&notifierOptions.Hashes),
+		&cli.BoolFlag{
+			Name:        FlagContinueOnError,
+			Aliases:     AliasesFlagContinueOnError,
+			Usage:       "Whether to keep importing the remaining leaks after one of them fails",
+			Required:    false,
+			Value:       false,
+			Destination: &continueOnError,
+		},
+	}
+
+	flags = append(flags, createParserFlags(&format, &parserOptions)...)
+	flags = append(flags, createErrorThresholdFlags(&errorThreshold)...)
+	flags = append(flags, createStoreFlags(&batchSize, &force)...)
+
+	sort.Sort(cli.FlagsByName(flags))
+
+	return &cli.Command{
+		Name:   CommandBatch,
+		Usage:  "Imports every leak described in a manifest file, without asking any questions",
+		Flags:  flags,
+		Action: CreateBatchAction(&manifestPath, &databasePath, &notifyTargets, &notifierOptions, &continueOnError, &format, &parserOptions, &errorThreshold, &batchSize, &force, storeImport, newNotifier, markNotified, loadNotification),
+	}
+}
+
+func CreateBatchAction(manifestPath *string, databasePath *string, notifyTargets *cli.StringSlice,
+	notifierOptions *importer.NotifierOptions, continueOnError *bool,
+	format *string, parserOptions *parser.LeakParserOptions, errorThreshold *importer.ErrorThreshold, batchSize *int,
+	force *bool,
+	storeImport importer.StoreImportFunc,
+	newNotifier importer.NewNotifierFunc,
+	markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
+) func(cCtx *cli.Context) error {
+	return func(cCtx *cli.Context) error {
+		manifest, err := importer.LoadManifest(*manifestPath)
+
+		if err != nil {
+			return err
+		}
+
+		targets := manifest.NotifyTargets()
+
+		if len(notifyTargets.Value()) != 0 {
+			targets = notifyTargets.Value()
+		}
+
+		notifiers, err := importer.NewNotifiers(targets, *notifierOptions, newNotifier)
+
+		if err != nil {
+			return err
+		}
+
+		im := importer.Importer{
+			DatabasePath:     manifest.DatabasePath,
+			Notifiers:        notifiers,
+			Store:            storeImport,
+			MarkNotified:     markNotified,
+			LoadNotification: loadNotification,
+			NotifyHashes:     notifierOptions.Hashes,
+			OnProgress:       logStoreProgress,
+			BatchSize:        *batchSize,
+			Force:            *force,
+		}
+
+		if len(*databasePath) != 0 {
+			im.DatabasePath = *databasePath
+		}
+
+		if err := validateNonEmptyValue(im.DatabasePath, FlagDatabasePath); err != nil {
+			return err
+		}
+
+		opts := newParseOptions(*format, *parserOptions, "", *errorThreshold)
+		results := make([]BatchResult, len(manifest.Leaks))
+		failed := 0
+
+		for i, e := range manifest.Leaks {
+			results[i] = BatchResult{
+				LeakPath: e.LeakPath,
+				Status:   BatchSkipped,
+			}
+
+			if (failed != 0 && !*continueOnError) || cCtx.Context.Err() != nil {
+				continue
+			}
+
+			logging.Aspirador.Info(fmt.Sprintf("Starting Import of leak %d of %d (%s)", i+1, len(manifest.Leaks), e.LeakPath))
+
+			results[i] = importManifestEntry(cCtx.Context, im, e, opts)
+
+			// Leaks that were already imported are not failures, so that a manifest can be imported again.
+			if results[i].Status == BatchDuplicate {
+				logging.Aspirador.Warning(fmt.Sprintf("Skipped import of %s: %v", e.LeakPath, results[i].Err))
+				continue
+			}
+
+			if results[i].Err != nil {
+				failed++
+				logging.Aspirador.Error(fmt.Sprintf("Failed to import %s: %v", e.LeakPath, results[i].Err))
+			}
+		}
+
+		if err := PrintBatchResults(os.Stdout, results); err != nil {
+			return err
+		}
+
+		if err := cCtx.Context.Err(); err != nil {
+			return fmt.Errorf("batch was interrupted: %w", err)
+		}
+
+		if failed != 0 {
+			return fmt.Errorf("%d of %d leaks could not be imported", failed, len(results))
+		}
+
+		return nil
+	}
+}
+
+func importManifestEntry(ctx context.Context, im importer.Importer, e importer.ManifestEntry, opts importer.ParseOptions) BatchResult {
+	result := BatchResult{
+		LeakPath: e.LeakPath,
+		Status:   BatchFailed,
+	}
+
+	leak, err := e.Leak()
+
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	lr, err := importer.Read(ctx, leak, opts)
+
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Users = lr.Users
+	result.Errors = lr.ErrorCount
+	result.LeakId, result.Err = im.Import(ctx, lr)
+
+	var derr *importer.DuplicateImportError
+
+	if errors.As(result.Err, &derr) {
+		result.Status = BatchDuplicate
+		result.LeakId = derr.LeakId
+	}
+
+	if result.Err == nil {
+		result.Status = BatchImported
+	}
+
+	return result
+}
+
+func PrintBatchResults(w io.Writer, results []BatchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "#\tLEAK\tSTATUS\tLEAK ID\tUSERS\tERRORS\tREASON")
+
+	for i, r := range results {
+		leakId, reason := "", ""
+
+		if r.Status == BatchImported || r.LeakId != 0 {
+			leakId = strconv.FormatInt(int64(r.LeakId), 10)
+		}
+
+		if r.Err != nil {
+			reason = r.Err.Error()
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n", i+1, r.LeakPath, r.Status, leakId, r.Users, r.Errors, reason)
+	}
+
+	return tw.Flush()
+}
@@ -5,12 +5,15 @@ import (
 	"sort"
 	"time"
 
-	"github.com/palavrapasse/damn/pkg/entity"
-	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/parser"
 	"github.com/urfave/cli/v2"
 )
 
-func CreateCliApp(storeImport func(databasePath string, i query.Import) (entity.AutoGenKey, error), notifyImport func(entity.AutoGenKey, string) error) cli.App {
+func CreateCliApp(storeImport importer.StoreImportFunc, newNotifier importer.NewNotifierFunc,
+	markNotified importer.MarkNotifiedFunc, loadNotification importer.LoadNotificationFunc,
+	deliverPending importer.DeliverPendingFunc,
+) cli.App {
 
 	var databasePath string
 	var leakPath string
@@ -18,8 +21,15 @@ func CreateCliApp(storeImport func(databasePath string, i query.Import) (entity.
 	var platforms cli.StringSlice
 	var shareDate cli.Timestamp
 	var leakers cli.StringSlice
-	var notifyNewLeakURL string
+	var notifyTargets cli.StringSlice
+	var notifierOptions importer.NotifierOptions
 	var skipInteractiveMode bool
+	var format string
+	var parserOptions parser.LeakParserOptions
+	var rejectsPath string
+	var errorThreshold importer.ErrorThreshold
+	var batchSize int
+	var force bool
 
 	app := &cli.App{
 		Name:                 "import",
@@ -31,8 +41,17 @@ func CreateCliApp(storeImport func(databasePath string, i query.Import) (entity.
 		HideHelp:             false,
 		HideVersion:          false,
 		Authors:              CreateCliAuthors(),
-		Flags:                CreateCliFlags(&databasePath, &leakPath, &context, &platforms, &shareDate, &leakers, &notifyNewLeakURL, &skipInteractiveMode),
-		Action:               CreateAction(&databasePath, &leakPath, &context, &platforms, &shareDate, &leakers, &notifyNewLeakURL, &skipInteractiveMode, storeImport, notifyImport),
+		ExitErrHandler:       func(cCtx *cli.Context, err error) {},
+		Commands: []*cli.Command{
+			CreateValidateCommand(),
+			CreateAppendCommand(storeImport, newNotifier, markNotified, loadNotification),
+			CreateNotifyCommand(newNotifier, markNotified, loadNotification, deliverPending),
+			CreatePreviewCommand(),
+			CreateBatchCommand(storeImport, newNotifier, markNotified, loadNotification),
+			CreateServeCommand(storeImport, newNotifier, markNotified, loadNotification),
+		},
+		Flags:  CreateCliFlags(&databasePath, &leakPath, &context, &platforms, &shareDate, &leakers, &notifyTargets, &notifierOptions, &skipInteractiveMode, &format, &parserOptions, &rejectsPath, &errorThreshold, &batchSize, &force),
+		Action: CreateAction(&databasePath, &leakPath, &context, &platforms, &shareDate, &leakers, &notifyTargets, &notifierOptions, &skipInteractiveMode, &format, &parserOptions, &rejectsPath, &errorThreshold, &batchSize, &force, storeImport, newNotifier, markNotified, loadNotification),
 	}
 
 	cli.AppHelpTemplate = CreateAppHelpTemplate(cli.AppHelpTemplate)
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	ExitCodeError          = 1
+	ExitCodeErrorThreshold = 3
+	ExitCodeDuplicateLeak  = 4
+	ExitCodeNotifyError    = 5
+	ExitCodeInterrupted    = 130
+)
+
+// ExitCode returns the exit code the program should finish with, given the error returned by the cli app.
+func ExitCode(err error) int {
+	var terr *importer.ThresholdError
+
+	if errors.As(err, &terr) {
+		return ExitCodeErrorThreshold
+	}
+
+	var derr *importer.DuplicateImportError
+
+	if errors.As(err, &derr) {
+		return ExitCodeDuplicateLeak
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ExitCodeInterrupted
+	}
+
+	var nerr *importer.NotificationError
+
+	if errors.As(err, &nerr) {
+		return ExitCodeNotifyError
+	}
+
+	var ecerr cli.ExitCoder
+
+	if errors.As(err, &ecerr) {
+		return ecerr.ExitCode()
+	}
+
+	return ExitCodeError
+}
@@ -1,10 +1,19 @@
 package cli
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/notify"
+	"github.com/palavrapasse/import/internal/parser"
 	"github.com/urfave/cli/v2"
 )
 
+// Environment variable that the secret of webhook notifications is read from.
+const NotifySecretEnvVar = "IMPORT_NOTIFY_SECRET"
+
 const (
 	FlagDatabasePath        = "database-path"
 	FlagLeakPath            = "leak-path"
@@ -12,35 +21,91 @@ const (
 	FlagLeakPlatforms       = "platforms"
 	FlagLeakShareDate       = "share-date"
 	FlagLeakers             = "leakers"
-	FlagNotifyNewLeakURL    = "notify url"
+	FlagNotify              = "notify"
 	FlagSkipInteractiveMode = "skip-interactive-mode"
+	FlagEmailColumn         = "email-column"
+	FlagPasswordColumn      = "password-column"
+	FlagEmailField          = "email-field"
+	FlagPasswordField       = "password-field"
+	FlagTable               = "table"
+	FlagSQLDialect          = "sql-dialect"
+	FlagInclude             = "include"
+	FlagFormat              = "format"
+	FlagSeparator           = "separator"
+	FlagSeparatorFallback   = "separator-fallback"
+	FlagRejectsPath         = "rejects-path"
+	FlagMaxErrors           = "max-errors"
+	FlagMaxErrorRatio       = "max-error-ratio"
+	FlagLimit               = "limit"
+	FlagShowPasswords       = "show-passwords"
+	FlagManifest            = "manifest"
+	FlagContinueOnError     = "continue-on-error"
+	FlagAddress             = "address"
+	FlagJobsPath            = "jobs-path"
+	FlagWorkers             = "workers"
+	FlagBatchSize           = "batch-size"
+	FlagForce               = "force"
+	FlagLeakId              = "leak-id"
+	FlagSkipNotification    = "skip-notification"
+	FlagPending             = "pending"
+	FlagNotifySecret        = "notify-secret"
+	FlagNotifyHashes        = "notify-hashes"
+	FlagMaxUploadSize       = "max-upload-size"
 )
 
 func CreateCliFlags(databasePath *string, leakPath *string, context *string,
 	platforms *cli.StringSlice, shareDate *cli.Timestamp, leakers *cli.StringSlice,
-	notifyNewLeakURL *string, skipInteractiveMode *bool,
+	notifyTargets *cli.StringSlice, notifierOptions *importer.NotifierOptions, skipInteractiveMode *bool,
+	format *string, parserOptions *parser.LeakParserOptions,
+	rejectsPath *string, errorThreshold *importer.ErrorThreshold, batchSize *int, force *bool,
 ) []cli.Flag {
 
-	return []cli.Flag{
+	// Flags of the root command are not required, otherwise they would be required by subcommands as well.
+	flags := []cli.Flag{
 		&cli.PathFlag{
 			Name:        FlagDatabasePath,
 			Aliases:     AliasesFlagDatabasePath,
 			Usage:       "Store leaks into `SQLite Database`",
-			Required:    true,
+			Required:    false,
 			Destination: databasePath,
 		},
+		createNotifyFlag(notifyTargets, "Notify `TARGET` of the new leak"),
+		createNotifySecretFlag(&notifierOptions.Secret),
+		createNotifyHashesFlag(&notifierOptions.Hashes),
+		&cli.BoolFlag{
+			Name:        FlagSkipInteractiveMode,
+			Aliases:     AliasesFlagSkipInteractiveMode,
+			Usage:       "Whether to skip questions the program might question you before taking any action",
+			Required:    false,
+			Value:       false,
+			Destination: skipInteractiveMode,
+		},
+	}
+
+	flags = append(flags, createLeakFlags(leakPath, context, platforms, shareDate, leakers, false)...)
+	flags = append(flags, createParserFlags(format, parserOptions)...)
+	flags = append(flags, createStoreFlags(batchSize, force)...)
+
+	return append(flags, createParseCheckFlags(rejectsPath, errorThreshold)...)
+}
+
+func createLeakFlags(leakPath *string, context *string, platforms *cli.StringSlice, shareDate *cli.Timestamp,
+	leakers *cli.StringSlice, required bool,
+) []cli.Flag {
+
+	return []cli.Flag{
 		&cli.PathFlag{
 			Name:        FlagLeakPath,
 			Aliases:     AliasesFlagLeakPath,
-			Usage:       "Load leak from `FILE`",
-			Required:    true,
+			Usage:       "Load leak from `FILE` (gzip, bzip2, xz, zstd, zip and tar files are supported)",
+			Required:    required,
 			Destination: leakPath,
 		},
 		&cli.StringFlag{
 			Name:        FlagLeakContext,
 			Aliases:     AliasesFlagLeakContext,
 			Usage:       "Leak Context",
-			Required:    true,
+			Required:    required,
 			Destination: context,
 		},
 		&cli.StringSliceFlag{
@@ -56,30 +121,187 @@ func CreateCliFlags(databasePath *string, leakPath *string, context *string,
 			Aliases:     AliasesFlagLeakShareDate,
 			Usage:       "Leak Share Date",
 			Layout:      query.DateFormatLayout,
-			Required:    true,
+			Required:    required,
 			Destination: shareDate,
 		},
 		&cli.StringSliceFlag{
 			Name:        FlagLeakers,
 			Aliases:     AliasesFlagLeakers,
 			Usage:       "Leakers (separated by commas)",
-			Required:    true,
+			Required:    required,
 			Destination: leakers,
 		},
+	}
+}
+
+func createParserFlags(format *string, parserOptions *parser.LeakParserOptions) []cli.Flag {
+
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        FlagEmailColumn,
+			Aliases:     AliasesFlagEmailColumn,
+			Usage:       "CSV column (header name or zero based index) or SQL column that contains the email",
+			Required:    false,
+			Destination: &parserOptions.EmailColumn,
+		},
+		&cli.StringFlag{
+			Name:        FlagPasswordColumn,
+			Aliases:     AliasesFlagPasswordColumn,
+			Usage:       "CSV column (header name or zero based index) or SQL column that contains the password",
+			Required:    false,
+			Destination: &parserOptions.PasswordColumn,
+		},
+		&cli.StringFlag{
+			Name:        FlagEmailField,
+			Aliases:     AliasesFlagEmailField,
+			Usage:       "JSON field path (dotted path or JSON Pointer) that contains the email",
+			Value:       parser.DefaultJSONEmailField,
+			Required:    false,
+			Destination: &parserOptions.EmailField,
+		},
 		&cli.StringFlag{
-			Name:        FlagNotifyNewLeakURL,
-			Aliases:     AliasesFlagNotifyNewLeakURL,
-			Usage:       "URL service to be notified of the new leak",
-			Required:    true,
-			Destination: notifyNewLeakURL,
+			Name:        FlagPasswordField,
+			Aliases:     AliasesFlagPasswordField,
+			Usage:       "JSON field path (dotted path or JSON Pointer) that contains the password",
+			Value:       parser.DefaultJSONPasswordField,
+			Required:    false,
+			Destination: &parserOptions.PasswordField,
+		},
+		&cli.StringFlag{
+			Name:        FlagTable,
+			Aliases:     AliasesFlagTable,
+			Usage:       "SQL table to import users from (by default, every table with email and password columns)",
+			Required:    false,
+			Destination: &parserOptions.Table,
+		},
+		&cli.StringFlag{
+			Name:        FlagSQLDialect,
+			Aliases:     AliasesFlagSQLDialect,
+			Usage:       fmt.Sprintf("SQL `DIALECT` of the dump (%s), which decides whether backslashes in strings are escapes. Inferred from the dump if empty", strings.Join(parser.SQLDialects, ", ")),
+			Required:    false,
+			Destination: &parserOptions.SQLDialect,
+		},
+		&cli.StringFlag{
+			Name:        FlagInclude,
+			Aliases:     AliasesFlagInclude,
+			Usage:       "Only import archive members that match the `GLOB` (e.g., \"*.txt\")",
+			Required:    false,
+			Destination: &parserOptions.Include,
+		},
+		&cli.StringFlag{
+			Name:        FlagFormat,
+			Aliases:     AliasesFlagFormat,
+			Usage:       fmt.Sprintf("Leak `FORMAT` (%s, %s)", parser.AutoFormat, strings.Join(parser.RegisteredFormats(), ", ")),
+			Value:       parser.AutoFormat,
+			Required:    false,
+			Destination: format,
+		},
+		&cli.StringFlag{
+			Name:        FlagSeparator,
+			Aliases:     AliasesFlagSeparator,
+			Usage:       "`SEPARATOR` between email and password (e.g., \"|\", \"\\t\" or \"tab\"). Inferred from the leak if empty",
+			Required:    false,
+			Destination: &parserOptions.Separator,
 		},
 		&cli.BoolFlag{
-			Name:        FlagSkipInteractiveMode,
-			Aliases:     AliasesFlagSkipInteractiveMode,
-			Usage:       "Whether to skip questions the program might question you before taking any action",
+			Name:        FlagSeparatorFallback,
+			Aliases:     AliasesFlagSeparatorFallback,
+			Usage:       "Whether to retry lines that can't be parsed with the separator using every other supported separator",
 			Required:    false,
 			Value:       false,
-			Destination: skipInteractiveMode,
+			Destination: &parserOptions.SeparatorFallback,
+		},
+	}
+}
+
+func createParseCheckFlags(rejectsPath *string, errorThreshold *importer.ErrorThreshold) []cli.Flag {
+
+	return append(createErrorThresholdFlags(errorThreshold), &cli.PathFlag{
+		Name:        FlagRejectsPath,
+		Aliases:     AliasesFlagRejectsPath,
+		Usage:       "Write lines that could not be parsed, and why, to `FILE` (NDJSON if it has a JSON extension, TSV otherwise)",
+		Required:    false,
+		Destination: rejectsPath,
+	})
+}
+
+func createErrorThresholdFlags(errorThreshold *importer.ErrorThreshold) []cli.Flag {
+
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        FlagMaxErrors,
+			Aliases:     AliasesFlagMaxErrors,
+			Usage:       "Abort the import if more than `N` lines could not be parsed (negative for no limit)",
+			Value:       importer.DefaultMaxErrors,
+			Required:    false,
+			Destination: &errorThreshold.MaxErrors,
+		},
+		&cli.Float64Flag{
+			Name:        FlagMaxErrorRatio,
+			Aliases:     AliasesFlagMaxErrorRatio,
+			Usage:       "Abort the import if the ratio of lines that could not be parsed exceeds `RATIO` (between 0 and 1)",
+			Value:       importer.DefaultMaxErrorRatio,
+			Required:    false,
+			Destination: &errorThreshold.MaxErrorRatio,
+		},
+	}
+}
+
+// Notification targets are repeatable, and are either an http(s) URL, exec:PATH or file:PATH.
+func createNotifyFlag(notifyTargets *cli.StringSlice, usage string) cli.Flag {
+
+	return &cli.StringSliceFlag{
+		Name:        FlagNotify,
+		Aliases:     AliasesFlagNotify,
+		Usage:       fmt.Sprintf("%s: an http(s) URL, %sPATH of a command or %sPATH of a file or FIFO (repeat the flag to notify several targets)", usage, notify.CommandTargetPrefix, notify.FileTargetPrefix),
+		Required:    false,
+		Destination: notifyTargets,
+	}
+}
+
+// The secret is also read from the environment, so that it does not show up in the list of processes.
+func createNotifySecretFlag(secret *string) cli.Flag {
+
+	return &cli.StringFlag{
+		Name:        FlagNotifySecret,
+		Aliases:     AliasesFlagNotifySecret,
+		Usage:       "Sign webhook notifications with the shared `SECRET`, so that services can verify them",
+		EnvVars:     []string{NotifySecretEnvVar},
+		Required:    false,
+		Destination: secret,
+	}
+}
+
+func createNotifyHashesFlag(hashes *bool) cli.Flag {
+
+	return &cli.BoolFlag{
+		Name:        FlagNotifyHashes,
+		Aliases:     AliasesFlagNotifyHashes,
+		Usage:       "Whether notifications should carry the SHA-256 hashes of the emails of affected users",
+		Required:    false,
+		Value:       false,
+		Destination: hashes,
+	}
+}
+
+func createStoreFlags(batchSize *int, force *bool) []cli.Flag {
+
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        FlagBatchSize,
+			Aliases:     AliasesFlagBatchSize,
+			Usage:       "Store `N` affected users at a time, all in the same transaction",
+			Value:       importer.DefaultBatchSize,
+			Required:    false,
+			Destination: batchSize,
+		},
+		&cli.BoolFlag{
+			Name:        FlagForce,
+			Aliases:     AliasesFlagForce,
+			Usage:       "Whether to import leaks whose users were already imported as another leak",
+			Required:    false,
+			Value:       false,
+			Destination: force,
 		},
 	}
 }
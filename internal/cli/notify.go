@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+	"github.com/urfave/cli/v2"
+)
+
+const CommandNotify = "notify"
+
+func CreateNotifyCommand(newNotifier importer.NewNotifierFunc, markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc, deliverPending importer.DeliverPendingFunc,
+) *cli.Command {
+	var databasePath string
+	var pending bool
+	var leakId int64
+	var notifyTargets cli.StringSlice
+	var notifierOptions importer.NotifierOptions
+
+	flags := []cli.Flag{
+		&cli.PathFlag{
+			Name:        FlagDatabasePath,
+			Aliases:     AliasesFlagDatabasePath,
+			Usage:       "Deliver notifications of the leaks stored in `SQLite Database`",
+			Required:    true,
+			Destination: &databasePath,
+		},
+		&cli.BoolFlag{
+			Name:        FlagPending,
+			Aliases:     AliasesFlagPending,
+			Usage:       "Whether to deliver every notification that is still pending",
+			Required:    false,
+			Value:       false,
+			Destination: &pending,
+		},
+		&cli.Int64Flag{
+			Name:        FlagLeakId,
+			Aliases:     AliasesFlagLeakId,
+			Usage:       "Notify the targets of the stored leak with `ID`",
+			Required:    false,
+			Destination: &leakId,
+		},
+		createNotifyFlag(&notifyTargets, "Notify `TARGET` of the stored leak"),
+		createNotifySecretFlag(&notifierOptions.Secret),
+		createNotifyHashesFlag(&notifierOptions.Hashes),
+	}
+
+	sort.Sort(cli.FlagsByName(flags))
+
+	return &cli.Command{
+		Name:   CommandNotify,
+		Usage:  "Delivers the notifications of stored leaks that could not be delivered when they were imported",
+		Flags:  flags,
+		Action: CreateNotifyAction(&databasePath, &pending, &leakId, &notifyTargets, &notifierOptions, newNotifier, markNotified, loadNotification, deliverPending),
+	}
+}
+
+// CreateNotifyAction delivers every pending notification if pending is set, so that it can be run on a schedule, or
+// notifies notifyTargets of the stored leak with leakId otherwise.
+func CreateNotifyAction(databasePath *string, pending *bool, leakId *int64, notifyTargets *cli.StringSlice,
+	notifierOptions *importer.NotifierOptions,
+	newNotifier importer.NewNotifierFunc,
+	markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
+	deliverPending importer.DeliverPendingFunc,
+) func(cCtx *cli.Context) error {
+	return func(cCtx *cli.Context) error {
+		logging.Aspirador.Info("Starting Notify")
+
+		if *pending {
+			if *leakId != 0 {
+				return fmt.Errorf("%s and %s should not be used together", FlagPending, FlagLeakId)
+			}
+
+			return deliverPending(cCtx.Context, *databasePath, newNotifier, *notifierOptions)
+		}
+
+		if *leakId <= 0 {
+			return fmt.Errorf("either %s or %s should be set", FlagPending, FlagLeakId)
+		}
+
+		if len(notifyTargets.Value()) == 0 {
+			return fmt.Errorf("%s should be set to notify leak %d", FlagNotify, *leakId)
+		}
+
+		notifiers, err := importer.NewNotifiers(notifyTargets.Value(), *notifierOptions, newNotifier)
+
+		if err != nil {
+			return err
+		}
+
+		im := importer.Importer{
+			DatabasePath:     *databasePath,
+			Notifiers:        notifiers,
+			MarkNotified:     markNotified,
+			LoadNotification: loadNotification,
+			NotifyHashes:     notifierOptions.Hashes,
+		}
+
+		return im.NotifyLeak(cCtx.Context, entity.AutoGenKey(*leakId))
+	}
+}
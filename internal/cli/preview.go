@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/parser"
+	"github.com/urfave/cli/v2"
+)
+
+const CommandPreview = "preview"
+
+const DefaultPreviewLimit = 20
+
+const maskedPasswordRune = "*"
+
+func CreatePreviewCommand() *cli.Command {
+	var leakPath string
+	var format string
+	var parserOptions parser.LeakParserOptions
+	var limit int
+	var showPasswords bool
+
+	flags := []cli.Flag{
+		&cli.PathFlag{
+			Name:        FlagLeakPath,
+			Aliases:     AliasesFlagLeakPath,
+			Usage:       "Load leak from `FILE` (gzip, bzip2, xz, zstd, zip and tar files are supported)",
+			Required:    true,
+			Destination: &leakPath,
+		},
+		&cli.IntFlag{
+			Name:        FlagLimit,
+			Aliases:     AliasesFlagLimit,
+			Usage:       "Number of records to preview",
+			Value:       DefaultPreviewLimit,
+			Required:    false,
+			Destination: &limit,
+		},
+		&cli.BoolFlag{
+			Name:        FlagShowPasswords,
+			Aliases:     AliasesFlagShowPasswords,
+			Usage:       "Whether to show passwords and raw records as they are, instead of masking them",
+			Required:    false,
+			Value:       false,
+			Destination: &showPasswords,
+		},
+	}
+
+	flags = append(flags, createParserFlags(&format, &parserOptions)...)
+
+	sort.Sort(cli.FlagsByName(flags))
+
+	return &cli.Command{
+		Name:   CommandPreview,
+		Usage:  "Prints how the first records of a leak are parsed",
+		Flags:  flags,
+		Action: CreatePreviewAction(&leakPath, &format, &parserOptions, &limit, &showPasswords),
+	}
+}
+
+func CreatePreviewAction(leakPath *string, format *string, parserOptions *parser.LeakParserOptions, limit *int,
+	showPasswords *bool,
+) func(cCtx *cli.Context) error {
+	return func(cCtx *cli.Context) error {
+		err := validateNonEmptyValue(*leakPath, FlagLeakPath)
+
+		if err != nil {
+			return err
+		}
+
+		if *limit <= 0 {
+			return fmt.Errorf("%s should be greater than zero", FlagLimit)
+		}
+
+		opts := *parserOptions
+		opts.FilePath = *leakPath
+		opts.Separator = parser.UnescapeSeparator(opts.Separator)
+
+		p, _, err := importer.NewLeakParser(*format, opts)
+
+		if err != nil {
+			return err
+		}
+
+		previewer, ok := p.(parser.LeakPreviewer)
+
+		if !ok {
+			return fmt.Errorf("%T does not support previews", p)
+		}
+
+		records, err := parser.PreviewLeakFile(opts.FilePath, opts.Include, previewer, *limit)
+
+		if err != nil {
+			return err
+		}
+
+		return PrintParsedRecords(os.Stdout, records, *showPasswords)
+	}
+}
+
+// PrintParsedRecords prints each record next to what was parsed from it. Unless showPasswords is set, passwords
+// are masked, and records are replaced by their redacted excerpt, since passwords can't be told apart in their
+// raw text once they are quoted or escaped.
+func PrintParsedRecords(w io.Writer, records []parser.ParsedRecord, showPasswords bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "LINE\tRECORD\t\tEMAIL\tPASSWORD")
+
+	for _, r := range records {
+		raw := r.Raw
+		password := string(r.Password)
+
+		if !showPasswords {
+			password = maskPassword(password)
+			raw = r.Excerpt
+		}
+
+		if r.Err != nil {
+			fmt.Fprintf(tw, "%d\t%s\t->\terror: %s: %v\t\n", r.Line, raw, r.Err.Reason, r.Err.Err)
+		} else {
+			fmt.Fprintf(tw, "%d\t%s\t->\t%s\t%s\n", r.Line, raw, r.Email, password)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// Masks every character of the password but the first one.
+func maskPassword(password string) string {
+	runes := []rune(password)
+
+	if len(runes) <= 1 {
+		return strings.Repeat(maskedPasswordRune, len(runes))
+	}
+
+	return string(runes[0]) + strings.Repeat(maskedPasswordRune, len(runes)-1)
+}
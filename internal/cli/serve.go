@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"sort"
+
+	"github.com/palavrapasse/import/internal/http"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/job"
+	"github.com/palavrapasse/import/internal/parser"
+	"github.com/urfave/cli/v2"
+)
+
+const CommandServe = "serve"
+
+// Address that import-web posts leaks to.
+const DefaultServeAddress = "0.0.0.0:55545"
+
+const DefaultJobsPath = "import-jobs.sqlite"
+
+func CreateServeCommand(storeImport importer.StoreImportFunc, newNotifier importer.NewNotifierFunc, markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
+) *cli.Command {
+	var address string
+	var jobsPath string
+	var workers int
+	var maxUploadSize int64
+	var databasePath string
+	var notifyTargets cli.StringSlice
+	var notifierOptions importer.NotifierOptions
+	var format string
+	var parserOptions parser.LeakParserOptions
+	var errorThreshold importer.ErrorThreshold
+	var batchSize int
+	var force bool
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        FlagAddress,
+			Aliases:     AliasesFlagAddress,
+			Usage:       "Listen for leak uploads on `HOST:PORT`",
+			Required:    false,
+			Value:       DefaultServeAddress,
+			Destination: &address,
+		},
+		&cli.PathFlag{
+			Name:        FlagJobsPath,
+			Aliases:     AliasesFlagJobsPath,
+			Usage:       "Keep the history of import jobs in `SQLite Database`",
+			Required:    false,
+			Value:       DefaultJobsPath,
+			Destination: &jobsPath,
+		},
+		&cli.IntFlag{
+			Name:        FlagWorkers,
+			Aliases:     AliasesFlagWorkers,
+			Usage:       "Maximum number of import jobs that run at the same time",
+			Required:    false,
+			Value:       job.DefaultWorkers,
+			Destination: &workers,
+		},
+		&cli.Int64Flag{
+			Name:        FlagMaxUploadSize,
+			Aliases:     AliasesFlagMaxUploadSize,
+			Usage:       "Refuse uploaded leaks larger than `N` bytes (negative for no limit)",
+			Required:    false,
+			Value:       http.DefaultMaxUploadSize,
+			Destination: &maxUploadSize,
+		},
+		&cli.PathFlag{
+			Name:        FlagDatabasePath,
+			Aliases:     AliasesFlagDatabasePath,
+			Usage:       "Store leaks into `SQLite Database`",
+			Required:    true,
+			Destination: &databasePath,
+		},
+		createNotifyFlag(&notifyTargets, "Notify `TARGET` of each new leak"),
+		createNotifySecretFlag(&notifierOptions.Secret),
+		createNotifyHashesFlag(&notifierOptions.Hashes),
+	}
+
+	flags = append(flags, createParserFlags(&format, &parserOptions)...)
+	flags = append(flags, createErrorThresholdFlags(&errorThreshold)...)
+	flags = append(flags, createStoreFlags(&batchSize, &force)...)
+
+	sort.Sort(cli.FlagsByName(flags))
+
+	return &cli.Command{
+		Name:   CommandServe,
+		Usage:  "Serves an HTTP API that imports leaks uploaded with the import-web form, either right away or as background jobs",
+		Flags:  flags,
+		Action: CreateServeAction(&address, &jobsPath, &workers, &maxUploadSize, &databasePath, &notifyTargets, &notifierOptions, &format, &parserOptions, &errorThreshold, &batchSize, &force, storeImport, newNotifier, markNotified, loadNotification),
+	}
+}
+
+func CreateServeAction(address *string, jobsPath *string, workers *int, maxUploadSize *int64, databasePath *string,
+	notifyTargets *cli.StringSlice, notifierOptions *importer.NotifierOptions,
+	format *string, parserOptions *parser.LeakParserOptions, errorThreshold *importer.ErrorThreshold, batchSize *int,
+	force *bool,
+	storeImport importer.StoreImportFunc,
+	newNotifier importer.NewNotifierFunc,
+	markNotified importer.MarkNotifiedFunc,
+	loadNotification importer.LoadNotificationFunc,
+) func(cCtx *cli.Context) error {
+	return func(cCtx *cli.Context) error {
+		if err := errorThreshold.Validate(); err != nil {
+			return err
+		}
+
+		notifiers, err := importer.NewNotifiers(notifyTargets.Value(), *notifierOptions, newNotifier)
+
+		if err != nil {
+			return err
+		}
+
+		// Leaks are stored one at a time, since both requests and jobs import them concurrently.
+		im := importer.Importer{
+			DatabasePath:     *databasePath,
+			Notifiers:        notifiers,
+			Store:            importer.SerializeStore(storeImport),
+			MarkNotified:     markNotified,
+			LoadNotification: loadNotification,
+			NotifyHashes:     notifierOptions.Hashes,
+			BatchSize:        *batchSize,
+			Force:            *force,
+		}
+
+		opts := newParseOptions(*format, *parserOptions, "", *errorThreshold)
+
+		store, err := job.OpenStore(*jobsPath)
+
+		if err != nil {
+			return err
+		}
+
+		defer store.Close()
+
+		jobs := job.NewQueue(store, im, opts, *workers)
+
+		if err := jobs.Start(cCtx.Context); err != nil {
+			return err
+		}
+
+		// Jobs are stopped along with the server, and waited for so that they are stored before the store closes.
+		defer jobs.Wait()
+
+		return http.NewImportServer(im, opts, jobs, *maxUploadSize).ListenAndServe(cCtx.Context, *address)
+	}
+}
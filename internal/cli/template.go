@@ -6,17 +6,36 @@ import (
 	"github.com/palavrapasse/damn/pkg/entity/query"
 )
 
-var exampleCommand = fmt.Sprintf(`./import --database-path="path/db.sqlite" --leak-path="path/file.txt" --context="context" --platforms="platform1, platform2" --share-date="%s" --leakers="leaker1, leaker2" --notify-url="https://subscribeService/notify" --skip=false`,
+var exampleCommand = fmt.Sprintf(`./import --database-path="path/db.sqlite" --leak-path="path/file.txt" --context="context" --platforms="platform1, platform2" --share-date="%s" --leakers="leaker1, leaker2" --notify="https://subscribeService/notify" --notify="exec:/usr/local/bin/on-leak" --skip=false`,
 	query.DateFormatLayout)
 
+var exampleValidateCommand = fmt.Sprintf(`./import validate --leak-path="path/file.txt" --context="context" --share-date="%s" --leakers="leaker1, leaker2" --rejects-path="path/rejects.tsv"`,
+	query.DateFormatLayout)
+
+var examplePreviewCommand = `./import preview --leak-path="path/file.txt" --limit=20`
+
+var exampleAppendCommand = `./import append --database-path="path/db.sqlite" --leak-id=1 --leak-path="path/part2.txt" --skip-notification`
+
+var exampleNotifyCommand = `./import notify --database-path="path/db.sqlite" --pending --notify-hashes`
+
+var exampleBatchCommand = `./import batch --manifest="path/leaks.yaml" --continue-on-error`
+
+var exampleServeCommand = `./import serve --address="0.0.0.0:55545" --database-path="path/db.sqlite" --notify="https://subscribeService/notify" --notify-secret="secret"`
+
 func CreateAppHelpTemplate(base string) string {
 
 	return fmt.Sprintf(`%s
 EXAMPLE: 
 	%s
+	%s
+	%s
+	%s
+	%s
+	%s
+	%s
 
 WEBSITE:
 	https://github.com/palavrapasse
 
-`, base, exampleCommand)
+`, base, exampleCommand, exampleValidateCommand, examplePreviewCommand, exampleAppendCommand, exampleNotifyCommand, exampleBatchCommand, exampleServeCommand)
 }
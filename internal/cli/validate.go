@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"fmt"
+	"hash/maphash"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+	"github.com/palavrapasse/import/internal/parser"
+	"github.com/urfave/cli/v2"
+)
+
+const CommandValidate = "validate"
+
+// Maximum number of distinct emails whose duplicates are counted, so that validating a large leak takes bounded
+// memory (about 40 bytes per email, so at most about 160 MiB).
+const MaxTrackedEmails = 1 << 22
+
+// LeakStats summarizes the parse of a leak. If DuplicatesPartial is set, Duplicates only counts the duplicates of
+// the first MaxTrackedEmails distinct emails of the leak.
+type LeakStats struct {
+	Format            string
+	Separator         string
+	Records           int
+	Users             int
+	Duplicates        int
+	Errors            int
+	ErrorReasons      map[parser.ParseErrorReason]int
+	DuplicatesPartial bool
+}
+
+// emailCounter counts the duplicate emails of a leak. Only the 64-bit digests of its first MaxTrackedEmails distinct
+// emails are kept, and emails found after that are not tracked.
+type emailCounter struct {
+	digests    map[uint64]struct{}
+	seed       maphash.Seed
+	duplicates int
+	untracked  bool
+}
+
+func CreateValidateCommand() *cli.Command {
+	var leakPath string
+	var context string
+	var platforms cli.StringSlice
+	var shareDate cli.Timestamp
+	var leakers cli.StringSlice
+	var format string
+	var parserOptions parser.LeakParserOptions
+	var rejectsPath string
+	var errorThreshold importer.ErrorThreshold
+
+	flags := createLeakFlags(&leakPath, &context, &platforms, &shareDate, &leakers, true)
+	flags = append(flags, createParserFlags(&format, &parserOptions)...)
+	flags = append(flags, createParseCheckFlags(&rejectsPath, &errorThreshold)...)
+
+	sort.Sort(cli.FlagsByName(flags))
+
+	return &cli.Command{
+		Name:   CommandValidate,
+		Usage:  "Parses a leak and validates its flags, printing statistics without storing it or notifying anyone",
+		Flags:  flags,
+		Action: CreateValidateAction(&leakPath, &context, &platforms, &shareDate, &leakers, &format, &parserOptions, &rejectsPath, &errorThreshold),
+	}
+}
+
+func CreateValidateAction(leakPath *string, context *string, platforms *cli.StringSlice, shareDate *cli.Timestamp,
+	leakers *cli.StringSlice, format *string, parserOptions *parser.LeakParserOptions, rejectsPath *string,
+	errorThreshold *importer.ErrorThreshold,
+) func(cCtx *cli.Context) error {
+	return func(cCtx *cli.Context) error {
+		logging.Aspirador.Info("Starting Validation")
+
+		leak := newLeak(*leakPath, *context, platforms, shareDate, leakers)
+		opts := newParseOptions(*format, *parserOptions, *rejectsPath, *errorThreshold)
+
+		emails := newEmailCounter()
+
+		opts.OnUsers = func(users query.LeakParse) {
+			for _, u := range users {
+				emails.add(u.Email)
+			}
+		}
+
+		lr, err := importer.Read(cCtx.Context, leak, opts)
+
+		if err != nil {
+			return err
+		}
+
+		stats := NewLeakStats(lr, emails.duplicates, emails.untracked)
+
+		if stats.Format == parser.PlainTextFormat {
+			stats.Separator = parser.UnescapeSeparator(parserOptions.Separator)
+
+			if len(stats.Separator) == 0 {
+				stats.Separator, err = parser.DetectSeparator(*leakPath, parserOptions.Include)
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		logging.Aspirador.Info("Successful Validation")
+
+		return PrintLeakStats(os.Stdout, stats)
+	}
+}
+
+// NewLeakStats computes the statistics of a parsed leak with duplicates users whose email was already found in the
+// leak, which were only partially counted if partial is set. Records are the users and the errors that belong to a
+// single record.
+func NewLeakStats(lr importer.LeakRead, duplicates int, partial bool) LeakStats {
+	return LeakStats{
+		Format:            lr.Format,
+		Records:           lr.Records,
+		Users:             lr.Users,
+		Duplicates:        duplicates,
+		Errors:            lr.ErrorCount,
+		ErrorReasons:      lr.ErrorReasons,
+		DuplicatesPartial: partial,
+	}
+}
+
+func PrintLeakStats(w io.Writer, stats LeakStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Format:\t%s\n", stats.Format)
+
+	if len(stats.Separator) != 0 {
+		fmt.Fprintf(tw, "Separator:\t%s\n", strconv.Quote(stats.Separator))
+	}
+
+	fmt.Fprintf(tw, "Records:\t%d\n", stats.Records)
+	fmt.Fprintf(tw, "Valid users:\t%d\n", stats.Users)
+	if stats.DuplicatesPartial {
+		fmt.Fprintf(tw, "Duplicates:\tat least %d (only the first %d distinct emails are tracked)\n", stats.Duplicates, MaxTrackedEmails)
+	} else {
+		fmt.Fprintf(tw, "Duplicates:\t%d\n", stats.Duplicates)
+	}
+	fmt.Fprintf(tw, "Errors:\t%d\n", stats.Errors)
+
+	for _, r := range parser.ParseErrorReasons() {
+		if n := stats.ErrorReasons[r]; n != 0 {
+			fmt.Fprintf(tw, "  %s:\t%d\n", r, n)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func newEmailCounter() *emailCounter {
+	return &emailCounter{
+		digests: map[uint64]struct{}{},
+		seed:    maphash.MakeSeed(),
+	}
+}
+
+// Counts email as a duplicate if its digest was already found. Two distinct emails only share a digest once in
+// billions of leaks of MaxTrackedEmails emails.
+func (c *emailCounter) add(email query.Email) {
+	digest := maphash.String(c.seed, string(email))
+
+	if _, ok := c.digests[digest]; ok {
+		c.duplicates++
+		return
+	}
+
+	if len(c.digests) == MaxTrackedEmails {
+		c.untracked = true
+		return
+	}
+
+	c.digests[digest] = struct{}{}
+}
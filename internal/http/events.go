@@ -0,0 +1,62 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/palavrapasse/import/internal/logging"
+)
+
+// Server-Sent Events of an import job. Progress events are sent while the job is queued or running, and a single
+// done event is sent once it finishes, after which the stream is closed.
+const (
+	ProgressEvent = "progress"
+	DoneEvent     = "done"
+)
+
+// Time between comments sent to keep idle event streams from being closed by proxies.
+const EventsKeepAliveInterval = 15 * time.Second
+
+var errStreamingUnsupported = errors.New("response writer does not support streaming")
+
+// eventStream writes Server-Sent Events to a response, flushing each one as soon as it is written.
+type eventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newEventStream(w http.ResponseWriter) (eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		return eventStream{}, errStreamingUnsupported
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return eventStream{w: w, flusher: flusher}, nil
+}
+
+func (s eventStream) send(event string, v any) {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		logging.Aspirador.Error(fmt.Sprintf("Could not encode %s event: %v", event, err))
+		return
+	}
+
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data)
+	s.flusher.Flush()
+}
+
+func (s eventStream) comment(text string) {
+	fmt.Fprintf(s.w, ": %s\n\n", text)
+	s.flusher.Flush()
+}
@@ -2,58 +2,255 @@ package http
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/import/internal/importer"
 	"github.com/palavrapasse/import/internal/logging"
+	"github.com/palavrapasse/import/pkg/webhook"
 )
 
-const MaxAttemptsNotify = 5
-const WaitingSecondsBetweenAttemptsNotify = 3
+// Maximum number of bytes of a response body that are read, so that connections can be reused.
+const maxDrainedResponseBytes = 4 << 10
+
+// DefaultNotifyPolicy is the policy webhooks follow.
+var DefaultNotifyPolicy = NotifyPolicy{
+	MaxAttempts:    5,
+	Timeout:        10 * time.Second,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	MaxRetryAfter:  2 * time.Minute,
+}
+
+// Source of the jitter of backoffs. The global source of math/rand is not seeded before Go 1.20, so this one is
+// seeded once. It is guarded by jitterMutex, since webhooks back off concurrently.
+var jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+var jitterMutex sync.Mutex
+
+// NotifyPolicy configures how a service is notified. Each attempt is given Timeout to complete. Failed attempts are
+// retried after an exponential backoff with jitter, which starts at InitialBackoff and is capped by MaxBackoff,
+// unless the service asks for a different delay with Retry-After, which is capped by MaxRetryAfter.
+type NotifyPolicy struct {
+	MaxAttempts    int
+	Timeout        time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxRetryAfter  time.Duration
+}
+
+// NotifyError is returned when a service could not be notified of a leak. Err is the error of the last attempt,
+// and StatusCode the status of its response, if there was one.
+type NotifyError struct {
+	Err        error
+	URL        string
+	LeakId     entity.AutoGenKey
+	Attempts   int
+	StatusCode int
+}
+
+// Webhook notifies the service of URL of stored leaks, following DefaultNotifyPolicy. Notifications carry the
+// unique id of their delivery, and are signed with Secret, unless it is empty, so that the service can verify them
+// with the webhook package.
+type Webhook struct {
+	URL    string
+	Secret []byte
+}
+
+// Failed attempt to notify a service. Retryable attempts can be retried, after retryAfter if the service asked for
+// a delay.
+type notifyAttemptError struct {
+	err        error
+	statusCode int
+	retryable  bool
+	retryAfter time.Duration
+}
+
+// Notify notifies the service of a new leak. It gives up as soon as ctx is done.
+func (w Webhook) Notify(ctx context.Context, n importer.Notification) error {
+	return notifyNewLeak(ctx, http.DefaultClient, DefaultNotifyPolicy, n, w.URL, w.Secret)
+}
+
+func (w Webhook) Target() string {
+	return w.URL
+}
+
+func (e *NotifyError) Error() string {
+	return fmt.Sprintf("could not notify %s of new leak %d after %d attempts: %v", e.URL, e.LeakId, e.Attempts, e.Err)
+}
+
+func (e *NotifyError) Unwrap() error {
+	return e.Err
+}
+
+// Every attempt is a delivery with the same id, so that the service can tell retries apart from new notifications.
+func notifyNewLeak(ctx context.Context, client *http.Client, policy NotifyPolicy, n importer.Notification, url string, secret []byte) error {
+	leakId := n.LeakId
 
-func NotifyNewLeak(leakId entity.AutoGenKey, subscribeServiceURL string) error {
 	logging.Aspirador.Info(fmt.Sprintf("Starting notification of new leak %d", leakId))
 
-	postBody, err := json.Marshal(map[string]int64{
-		"leakId": int64(leakId),
-	})
+	body, err := n.Payload()
 
 	if err != nil {
 		return err
 	}
 
-	responseBody := bytes.NewBuffer(postBody)
+	deliveryId, err := webhook.NewDeliveryId()
 
-	attempt := 1
-	var resp *http.Response
+	if err != nil {
+		return err
+	}
+
+	nerr := &NotifyError{
+		URL:    url,
+		LeakId: leakId,
+	}
+
+	for nerr.Attempts < policy.MaxAttempts {
+		nerr.Attempts++
+
+		aerr := notifyAttempt(ctx, client, policy.Timeout, url, body, deliveryId, secret)
+
+		if aerr == nil {
+			logging.Aspirador.Info(fmt.Sprintf("Successful notification of new leak %d", leakId))
+			return nil
+		}
+
+		nerr.Err = aerr.err
+		nerr.StatusCode = aerr.statusCode
 
-	for attempt <= MaxAttemptsNotify {
-		resp, err = http.Post(subscribeServiceURL, "application/json", responseBody)
+		if err := ctx.Err(); err != nil {
+			nerr.Err = fmt.Errorf("notification was interrupted: %w", err)
+			return nerr
+		}
 
-		if err != nil {
-			logging.Aspirador.Error(fmt.Sprintf("Error occured: '%s'. Trying again (done %d attempts)", err, attempt))
-		} else if resp.StatusCode == http.StatusNoContent {
+		if !aerr.retryable || nerr.Attempts == policy.MaxAttempts {
 			break
-		} else {
-			logging.Aspirador.Warning(fmt.Sprintf("Expected %d status but received %d status. Trying again (done %d attempts)", http.StatusNoContent, resp.StatusCode, attempt))
 		}
 
-		logging.Aspirador.Trace(fmt.Sprintf("Waiting %d seconds...", WaitingSecondsBetweenAttemptsNotify))
-		time.Sleep(WaitingSecondsBetweenAttemptsNotify * time.Second)
+		wait := policy.backoff(nerr.Attempts)
 
-		attempt++
-	}
+		if aerr.retryAfter > 0 {
+			wait = minDuration(aerr.retryAfter, policy.MaxRetryAfter)
+		}
+
+		logging.Aspirador.Warning(fmt.Sprintf("Attempt %d of %d to notify new leak %d failed: %v. Trying again in %v", nerr.Attempts, policy.MaxAttempts, leakId, aerr.err, wait))
+
+		timer := time.NewTimer(wait)
 
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			resp.Body.Close()
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			nerr.Err = fmt.Errorf("notification was interrupted: %w", ctx.Err())
+
+			return nerr
+		case <-timer.C:
 		}
-	}()
+	}
+
+	return nerr
+}
+
+// Posts body to url, with a fresh reader so that every attempt sends the whole body. Each attempt is signed when it
+// is sent, so that retries are not refused for being too old.
+func notifyAttempt(ctx context.Context, client *http.Client, timeout time.Duration, url string, body []byte, deliveryId string, secret []byte) *notifyAttemptError {
+	if timeout > 0 {
+		var cancel context.CancelFunc
 
-	logging.Aspirador.Info("Successful notification of new leak")
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+
+	if err != nil {
+		return &notifyAttemptError{err: err}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.DeliveryIdHeader, deliveryId)
+
+	if len(secret) != 0 {
+		webhook.SignRequest(req, secret, time.Now(), deliveryId, body)
+	}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return &notifyAttemptError{err: err, retryable: true}
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrainedResponseBytes)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return &notifyAttemptError{
+		err:        fmt.Errorf("unexpected response status %s", resp.Status),
+		statusCode: resp.StatusCode,
+		retryable:  isRetryableStatus(resp.StatusCode),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// Returns the delay before the attempt that follows the given attempt. The delay doubles with each attempt, and
+// half of it is random, so that services are not notified in lockstep.
+func (p NotifyPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	delay = minDuration(delay, p.MaxBackoff)
+	half := delay / 2
+
+	if half <= 0 {
+		return delay
+	}
+
+	jitterMutex.Lock()
+	defer jitterMutex.Unlock()
+
+	return half + time.Duration(jitterRand.Int63n(int64(half)+1))
+}
+
+// Server errors, rate limits and timeouts are assumed to be transient, and other client errors to be permanent.
+func isRetryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests || status == http.StatusRequestTimeout
+}
+
+// Parses a Retry-After header, which is either a number of seconds or an HTTP date. Returns 0 if there is none.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+func minDuration(a time.Duration, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
 
-	return nil
+	return b
 }
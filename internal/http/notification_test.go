@@ -0,0 +1,202 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/palavrapasse/import/internal/importer/importertest"
+	"github.com/palavrapasse/import/pkg/webhook"
+)
+
+var testNotifyPolicy = NotifyPolicy{
+	MaxAttempts:    3,
+	Timeout:        time.Second,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+	MaxRetryAfter:  10 * time.Millisecond,
+}
+
+func TestNotifyNewLeakSendsWholeBodyOnEveryAttempt(t *testing.T) {
+	var attempts atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if string(body) != importertest.Payload(7) {
+			t.Errorf("Attempt %d should send the notification of leak 7, but sent %q", attempts.Load()+1, body)
+		}
+
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer ts.Close()
+
+	if err := notifyNewLeak(context.Background(), ts.Client(), testNotifyPolicy, importertest.NewNotification(7), ts.URL, nil); err != nil {
+		t.Fatalf("Service should be notified on the third attempt, but got %v", err)
+	}
+
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("Service should be notified on the third attempt, but it took %d", n)
+	}
+}
+
+func TestNotifyNewLeakSignsEveryAttemptOfTheSameDelivery(t *testing.T) {
+	var attempts atomic.Int32
+	var mutex sync.Mutex
+	var deliveries []string
+
+	secret := []byte("shared secret")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := webhook.VerifyRequest(r, secret, webhook.DefaultTolerance, webhook.DefaultMaxBodySize); err != nil {
+			t.Errorf("Attempt %d should be signed, but got %v", attempts.Load()+1, err)
+		}
+
+		mutex.Lock()
+		deliveries = append(deliveries, r.Header.Get(webhook.DeliveryIdHeader))
+		mutex.Unlock()
+
+		if attempts.Add(1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer ts.Close()
+
+	if err := notifyNewLeak(context.Background(), ts.Client(), testNotifyPolicy, importertest.NewNotification(7), ts.URL, secret); err != nil {
+		t.Fatalf("Service should be notified on the second attempt, but got %v", err)
+	}
+
+	if len(deliveries) != 2 || len(deliveries[0]) == 0 || deliveries[0] != deliveries[1] {
+		t.Fatalf("Attempts should carry the same delivery id, but got %v", deliveries)
+	}
+}
+
+func TestNotifyNewLeakFailsAfterEveryAttemptFails(t *testing.T) {
+	var attempts atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	defer ts.Close()
+
+	err := notifyNewLeak(context.Background(), ts.Client(), testNotifyPolicy, importertest.NewNotification(7), ts.URL, nil)
+
+	var nerr *NotifyError
+
+	if !errors.As(err, &nerr) || nerr.Attempts != 3 || nerr.StatusCode != http.StatusBadGateway || attempts.Load() != 3 {
+		t.Fatalf("Notification should fail after 3 attempts, but got %v", err)
+	}
+}
+
+func TestNotifyNewLeakDoesNotRetryClientErrors(t *testing.T) {
+	var attempts atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	defer ts.Close()
+
+	err := notifyNewLeak(context.Background(), ts.Client(), testNotifyPolicy, importertest.NewNotification(7), ts.URL, nil)
+
+	var nerr *NotifyError
+
+	if !errors.As(err, &nerr) || attempts.Load() != 1 {
+		t.Fatalf("Bad requests should not be retried, but got %d attempts (%v)", attempts.Load(), err)
+	}
+}
+
+func TestNotifyNewLeakStopsOnceContextIsDone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := Webhook{URL: ts.URL}.Notify(ctx, importertest.NewNotification(1))
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Notification should be interrupted, but got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Notification should stop waiting for the next attempt once interrupted, but it took %v", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 02 Jan 2023 00:00:30 GMT": 30 * time.Second,
+		"Sun, 01 Jan 2023 00:00:00 GMT": 0,
+	}
+
+	for value, expected := range tests {
+		if d := parseRetryAfter(value, now); d != expected {
+			t.Fatalf("Retry-After %q should be parsed as %v, but got %v", value, expected, d)
+		}
+	}
+}
+
+func TestNotifyPolicyBackoffGrowsUpToMaxBackoff(t *testing.T) {
+	p := NotifyPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	for attempt, ceiling := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		d := p.backoff(attempt + 1)
+
+		if d < ceiling/2 || d > ceiling {
+			t.Fatalf("Backoff after attempt %d should be between %v and %v, but got %v", attempt+1, ceiling/2, ceiling, d)
+		}
+	}
+}
+
+func TestNotifyPolicyBackoffIsRandom(t *testing.T) {
+	p := NotifyPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	backoffs := map[time.Duration]bool{}
+
+	for i := 0; i < 10; i++ {
+		backoffs[p.backoff(1)] = true
+	}
+
+	if len(backoffs) == 1 {
+		t.Fatalf("Backoffs after the same attempt should be spread apart, but all of them were %v", p.backoff(1))
+	}
+}
+
+func TestNotifyErrorDescribesLastAttempt(t *testing.T) {
+	err := &NotifyError{Err: errors.New("unexpected response status 502 Bad Gateway"), URL: "https://subscribeService/notify", LeakId: 7, Attempts: 5}
+
+	if msg := err.Error(); msg != "could not notify https://subscribeService/notify of new leak 7 after 5 attempts: unexpected response status 502 Bad Gateway" {
+		t.Fatalf("Notify error should describe the last attempt, but got %s", msg)
+	}
+}
@@ -0,0 +1,514 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/job"
+	"github.com/palavrapasse/import/internal/logging"
+	"github.com/palavrapasse/import/internal/parser"
+)
+
+const (
+	FormFieldContext     = "context"
+	FormFieldShareDateMS = "shareDateMS"
+	FormFieldPlatforms   = "platforms"
+	FormFieldLeakers     = "leakers"
+	FormFieldLeakFile    = "leakFile"
+)
+
+const (
+	ImportPath        = "/"
+	ImportsPath       = "/imports"
+	EventsPathSegment = "events"
+)
+
+// Size of the uploaded form that is kept in memory. The rest is stored in temporary files.
+const MaxMultipartMemory = 32 << 20
+
+// Size of the largest upload that is accepted, by default.
+const DefaultMaxUploadSize = 2 << 30
+
+// Maximum number of parse errors that are listed in an import response.
+const MaxResponseParseErrors = 100
+
+const ReadHeaderTimeout = 10 * time.Second
+
+// Time given to requests in flight to finish once the server is stopped.
+const ShutdownTimeout = 30 * time.Second
+
+var corsHeaders = map[string]string{
+	"Access-Control-Allow-Origin":  "*",
+	"Access-Control-Allow-Methods": "OPTIONS, GET, POST",
+}
+
+// ImportServer imports leaks uploaded through the same multipart form used by import-web. Leaks posted to
+// ImportPath are imported while the request is held open, and leaks posted to ImportsPath are queued as jobs
+// whose state can be polled. Uploads larger than MaxUploadSize bytes are refused, unless it is negative.
+type ImportServer struct {
+	Importer      importer.Importer
+	Options       importer.ParseOptions
+	Jobs          *job.Queue
+	MaxUploadSize int64
+}
+
+type ImportResponse struct {
+	Error       string               `json:"error,omitempty"`
+	Format      string               `json:"format,omitempty"`
+	ParseErrors []ParseErrorResponse `json:"parseErrors,omitempty"`
+	LeakId      int64                `json:"leakId,omitempty"`
+	Users       int                  `json:"users"`
+	Errors      int                  `json:"errors"`
+}
+
+type ParseErrorResponse struct {
+	Reason  string `json:"reason,omitempty"`
+	Excerpt string `json:"excerpt,omitempty"`
+	Message string `json:"message"`
+	Offset  int64  `json:"offset"`
+	Line    int    `json:"line"`
+}
+
+// JobResponse describes an import job. Records and Users are the number of records parsed and users stored
+// so far, and Progress describes the current phase of running jobs.
+type JobResponse struct {
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Progress  *ProgressResponse `json:"progress,omitempty"`
+	State     job.State         `json:"state"`
+	FileName  string            `json:"fileName"`
+	Context   string            `json:"context"`
+	Format    string            `json:"format,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Id        int64             `json:"id"`
+	LeakId    int64             `json:"leakId,omitempty"`
+	Records   int               `json:"records"`
+	Users     int               `json:"users"`
+	Errors    int               `json:"errors"`
+}
+
+// ProgressResponse describes the current phase of a running job. ETASeconds is 0 while it cannot be estimated.
+type ProgressResponse struct {
+	Phase      importer.Phase `json:"phase"`
+	Fraction   float64        `json:"fraction"`
+	ETASeconds float64        `json:"etaSeconds"`
+	BytesRead  int64          `json:"bytesRead"`
+	BytesTotal int64          `json:"bytesTotal"`
+	Records    int            `json:"records"`
+	Users      int            `json:"users"`
+	UsersTotal int            `json:"usersTotal"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewImportServer creates a server that imports leaks with im, refusing uploads larger than maxUploadSize bytes
+// unless it is negative. Since leaks can be imported concurrently, the store of im should be serialized with
+// importer.SerializeStore, and shared with the queue of jobs.
+func NewImportServer(im importer.Importer, opts importer.ParseOptions, jobs *job.Queue, maxUploadSize int64) *ImportServer {
+	return &ImportServer{
+		Importer:      im,
+		Options:       opts,
+		Jobs:          jobs,
+		MaxUploadSize: maxUploadSize,
+	}
+}
+
+// ListenAndServe serves imports on addr until the server fails or ctx is done. Requests are given the context
+// of the server, so imports in flight are interrupted and rolled back once ctx is done.
+func (s *ImportServer) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		BaseContext:       func(l net.Listener) context.Context { return ctx },
+	}
+
+	stopped := make(chan error, 1)
+
+	go func() {
+		<-ctx.Done()
+
+		logging.Aspirador.Info("Stopping server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+
+		stopped <- server.Shutdown(shutdownCtx)
+	}()
+
+	logging.Aspirador.Info(fmt.Sprintf("Serving imports on %s", addr))
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err := <-stopped; err != nil {
+		return err
+	}
+
+	return fmt.Errorf("server was stopped: %w", ctx.Err())
+}
+
+func (s *ImportServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ImportPath, withCORS(s.handleImport))
+	mux.HandleFunc(ImportsPath, withCORS(s.handleSubmitJob))
+	mux.HandleFunc(ImportsPath+"/", withCORS(s.handleJob))
+
+	return mux
+}
+
+func (s *ImportServer) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != ImportPath {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	status, resp := s.importUpload(w, r)
+
+	writeJSON(w, status, resp)
+}
+
+func (s *ImportServer) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	leak, fileName, status, err := s.readUpload(w, r)
+
+	if err != nil {
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+
+	j, err := s.Jobs.Submit(leak, fileName)
+
+	if err != nil {
+		_ = job.RemoveUpload(leak.Path)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	logging.Aspirador.Info(fmt.Sprintf("Queued import job %d (%s)", j.Id, fileName))
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", ImportsPath, j.Id))
+	writeJSON(w, http.StatusAccepted, newJobResponse(j))
+}
+
+// Handles both ImportsPath/{id} and ImportsPath/{id}/events.
+func (s *ImportServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, ImportsPath+"/"), "/")
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+
+	switch {
+	case err != nil || len(segments) > 2:
+		http.NotFound(w, r)
+	case len(segments) == 2 && segments[1] == EventsPathSegment:
+		s.handleJobEvents(w, r, id)
+	case len(segments) == 1:
+		s.handleGetJob(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *ImportServer) handleGetJob(w http.ResponseWriter, r *http.Request, id int64) {
+	j, err := s.Jobs.Get(id)
+
+	if errors.Is(err, job.ErrJobNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newJobResponse(j))
+}
+
+func (s *ImportServer) handleJobEvents(w http.ResponseWriter, r *http.Request, id int64) {
+	updates, unsubscribe, err := s.Jobs.Subscribe(id)
+
+	if errors.Is(err, job.ErrJobNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	defer unsubscribe()
+
+	events, err := newEventStream(w)
+
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	keepAlive := time.NewTicker(EventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			events.comment("keep-alive")
+		case j, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			event := ProgressEvent
+
+			if j.Finished() {
+				event = DoneEvent
+			}
+
+			events.send(event, newJobResponse(j))
+		}
+	}
+}
+
+func (s *ImportServer) importUpload(w http.ResponseWriter, r *http.Request) (int, ImportResponse) {
+	leak, fileName, status, err := s.readUpload(w, r)
+
+	if err != nil {
+		return status, ImportResponse{Error: err.Error()}
+	}
+
+	defer job.RemoveUpload(leak.Path)
+
+	logging.Aspirador.Info(fmt.Sprintf("Starting Import of uploaded leak %s", fileName))
+
+	lr, err := importer.Read(r.Context(), leak, s.Options)
+
+	if err != nil {
+		var terr *importer.ThresholdError
+
+		if errors.As(err, &terr) {
+			return http.StatusUnprocessableEntity, ImportResponse{Error: err.Error()}
+		}
+
+		return http.StatusBadRequest, ImportResponse{Error: err.Error()}
+	}
+
+	resp := newImportResponse(lr)
+
+	leakId, err := s.Importer.Import(r.Context(), lr)
+
+	var derr *importer.DuplicateImportError
+
+	if errors.As(err, &derr) {
+		resp.Error = err.Error()
+		resp.LeakId = int64(derr.LeakId)
+
+		return http.StatusConflict, resp
+	}
+
+	var nerr *importer.NotificationError
+
+	// The leak was stored, but some of its targets could not be notified of it.
+	if errors.As(err, &nerr) {
+		resp.Error = err.Error()
+		resp.LeakId = int64(leakId)
+
+		return http.StatusBadGateway, resp
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+		return http.StatusInternalServerError, resp
+	}
+
+	resp.LeakId = int64(leakId)
+
+	return http.StatusOK, resp
+}
+
+// Reads the leak form and saves the uploaded leak, returning the status of the response if it fails. Forms larger
+// than MaxUploadSize are refused with 413 Request Entity Too Large.
+func (s *ImportServer) readUpload(w http.ResponseWriter, r *http.Request) (importer.Leak, string, int, error) {
+	if s.MaxUploadSize >= 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
+	}
+
+	if err := r.ParseMultipartForm(MaxMultipartMemory); err != nil {
+		var merr *http.MaxBytesError
+
+		if errors.As(err, &merr) {
+			return importer.Leak{}, "", http.StatusRequestEntityTooLarge, fmt.Errorf("upload should not be larger than %d bytes", merr.Limit)
+		}
+
+		return importer.Leak{}, "", http.StatusBadRequest, err
+	}
+
+	defer r.MultipartForm.RemoveAll()
+
+	leak, err := readLeakForm(r)
+
+	if err != nil {
+		return leak, "", http.StatusBadRequest, err
+	}
+
+	file, header, err := r.FormFile(FormFieldLeakFile)
+
+	if err != nil {
+		return leak, "", http.StatusBadRequest, fmt.Errorf("%s should be uploaded: %w", FormFieldLeakFile, err)
+	}
+
+	defer file.Close()
+
+	leak.Path, err = job.SaveUpload(file, header.Filename)
+
+	if err != nil {
+		_ = job.RemoveUpload(leak.Path)
+		return leak, header.Filename, http.StatusInternalServerError, err
+	}
+
+	return leak, header.Filename, http.StatusOK, nil
+}
+
+func readLeakForm(r *http.Request) (importer.Leak, error) {
+	leak := importer.Leak{
+		Context:   r.FormValue(FormFieldContext),
+		Platforms: importer.SplitList(r.FormValue(FormFieldPlatforms)),
+		Leakers:   importer.SplitList(r.FormValue(FormFieldLeakers)),
+	}
+
+	shareDateMS, err := strconv.ParseInt(r.FormValue(FormFieldShareDateMS), 10, 64)
+
+	if err != nil {
+		return leak, fmt.Errorf("%s should be the number of milliseconds since epoch: %w", FormFieldShareDateMS, err)
+	}
+
+	leak.ShareDate = time.UnixMilli(shareDateMS).UTC()
+
+	return leak, nil
+}
+
+func newImportResponse(lr importer.LeakRead) ImportResponse {
+	resp := ImportResponse{
+		Format: lr.Format,
+		Users:  lr.Users,
+		Errors: lr.ErrorCount,
+	}
+
+	for i, err := range lr.Errors {
+		if i == MaxResponseParseErrors {
+			break
+		}
+
+		resp.ParseErrors = append(resp.ParseErrors, newParseErrorResponse(err))
+	}
+
+	return resp
+}
+
+func newParseErrorResponse(err error) ParseErrorResponse {
+	var perr *parser.ParseError
+
+	if !errors.As(err, &perr) {
+		return ParseErrorResponse{Message: err.Error()}
+	}
+
+	return ParseErrorResponse{
+		Reason:  perr.Reason.String(),
+		Excerpt: perr.Excerpt,
+		Message: perr.Err.Error(),
+		Offset:  perr.Offset,
+		Line:    perr.Line,
+	}
+}
+
+func newJobResponse(j job.Job) JobResponse {
+	var progress *ProgressResponse
+
+	if j.State == job.Running && len(j.Progress.Phase) != 0 {
+		progress = &ProgressResponse{
+			Phase:      j.Progress.Phase,
+			Fraction:   j.Progress.Fraction(),
+			ETASeconds: j.Progress.ETA(time.Now()).Seconds(),
+			BytesRead:  j.Progress.BytesRead,
+			BytesTotal: j.Progress.BytesTotal,
+			Records:    j.Progress.Records,
+			Users:      j.Progress.Users,
+			UsersTotal: j.Progress.UsersTotal,
+		}
+	}
+
+	return JobResponse{
+		Progress:  progress,
+		CreatedAt: j.CreatedAt,
+		UpdatedAt: j.UpdatedAt,
+		State:     j.State,
+		FileName:  j.FileName,
+		Context:   j.Leak.Context,
+		Format:    j.Format,
+		Error:     j.Error,
+		Id:        j.Id,
+		LeakId:    j.LeakId,
+		Records:   j.Records,
+		Users:     j.Users,
+		Errors:    j.Errors,
+	}
+}
+
+func withCORS(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range corsHeaders {
+			w.Header().Set(k, v)
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// Replies with 405 and returns false if the request method is not method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Aspirador.Error(fmt.Sprintf("Could not write response: %v", err))
+	}
+}
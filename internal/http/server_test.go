@@ -0,0 +1,370 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/importer/importertest"
+	"github.com/palavrapasse/import/internal/job"
+	"github.com/palavrapasse/import/internal/parser"
+)
+
+func TestServerImportsUploadedLeak(t *testing.T) {
+	var stored query.Import
+
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		stored = i
+
+		return 7, opts.Users(func(users query.LeakParse) error {
+			stored.AffectedUsers = append(stored.AffectedUsers, users...)
+			return nil
+		})
+	})
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "0",
+		FormFieldPlatforms:   "platform1,platform2",
+		FormFieldLeakers:     "leaker",
+	}
+
+	rec := postLeakForm(server, ImportPath, fields, "leak.txt", "a@example.com:password\nmalformed\nb@example.com:password\n")
+	resp := decodeImportResponse(t, rec)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Uploaded leak should be imported, but got status %d (%s)", rec.Code, resp.Error)
+	}
+
+	if resp.LeakId != 7 || resp.Users != 2 || resp.Errors != 1 || len(resp.ParseErrors) != 1 {
+		t.Fatalf("Response should describe the imported leak, but got %v", resp)
+	}
+
+	if len(stored.AffectedPlatforms) != 2 || len(stored.Leakers) != 1 || len(stored.AffectedUsers) != 2 {
+		t.Fatalf("Form fields should be stored with the leak, but got %v", stored)
+	}
+}
+
+func TestServerRejectsLeakWithInvalidShareDate(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		t.Fatalf("Leak with invalid share date should not be stored")
+		return 0, nil
+	})
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "yesterday",
+		FormFieldPlatforms:   "platform",
+		FormFieldLeakers:     "leaker",
+	}
+
+	rec := postLeakForm(server, ImportPath, fields, "leak.txt", "a@example.com:password\n")
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Share date should be a number of milliseconds, but got status %d", rec.Code)
+	}
+
+	if resp := decodeImportResponse(t, rec); len(resp.Error) == 0 {
+		t.Fatalf("Response of a rejected leak should describe the error")
+	}
+}
+
+func TestServerRefusesUploadLargerThanMaxUploadSize(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		t.Fatalf("Leak that is too large should not be stored")
+		return 0, nil
+	})
+
+	server.MaxUploadSize = 1024
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "0",
+		FormFieldPlatforms:   "platform",
+		FormFieldLeakers:     "leaker",
+	}
+
+	for _, path := range []string{ImportPath, ImportsPath} {
+		rec := postLeakForm(server, path, fields, "leak.txt", strings.Repeat("a@example.com:password\n", 100))
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("Upload to %s larger than 1024 bytes should be refused, but got status %d", path, rec.Code)
+		}
+	}
+}
+
+func TestServerAbortsLeakThatExceedsErrorThreshold(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		t.Fatalf("Leak that exceeds the error threshold should not be stored")
+		return 0, nil
+	})
+
+	server.Options.ErrorThreshold.MaxErrors = 0
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "0",
+		FormFieldPlatforms:   "platform",
+		FormFieldLeakers:     "leaker",
+	}
+
+	rec := postLeakForm(server, ImportPath, fields, "leak.txt", "a@example.com:password\nmalformed\n")
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Leak with more errors than allowed should be unprocessable, but got status %d", rec.Code)
+	}
+}
+
+func TestServerRefusesLeakThatWasAlreadyImported(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		return 0, &importer.DuplicateImportError{Fingerprint: opts.Fingerprint, LeakId: 3}
+	})
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "0",
+		FormFieldPlatforms:   "platform",
+		FormFieldLeakers:     "leaker",
+	}
+
+	rec := postLeakForm(server, ImportPath, fields, "leak.txt", "a@example.com:password\n")
+	resp := decodeImportResponse(t, rec)
+
+	if rec.Code != http.StatusConflict || resp.LeakId != 3 {
+		t.Fatalf("Leak that was already imported should conflict with leak 3, but got status %d (%v)", rec.Code, resp)
+	}
+}
+
+func TestServerReportsLeakThatCouldNotBeNotified(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		return 7, nil
+	})
+
+	server.Importer.Notifiers = []importer.Notifier{importertest.Notifier{OnNotify: func(n importer.Notification) error {
+		return &NotifyError{Err: fmt.Errorf("unexpected response status 502 Bad Gateway"), URL: importertest.DefaultTarget, LeakId: n.LeakId, Attempts: 5}
+	}}}
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "0",
+		FormFieldPlatforms:   "platform",
+		FormFieldLeakers:     "leaker",
+	}
+
+	rec := postLeakForm(server, ImportPath, fields, "leak.txt", "a@example.com:password\n")
+	resp := decodeImportResponse(t, rec)
+
+	if rec.Code != http.StatusBadGateway || resp.LeakId != 7 || len(resp.Error) == 0 {
+		t.Fatalf("Stored leak that could not be notified should be reported as leak 7, but got status %d (%v)", rec.Code, resp)
+	}
+}
+
+func TestServerRunsSubmittedImportJob(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		return 7, nil
+	})
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "0",
+		FormFieldPlatforms:   "platform",
+		FormFieldLeakers:     "leaker",
+	}
+
+	rec := postLeakForm(server, ImportsPath, fields, "leak.txt", "a@example.com:password\nmalformed\n")
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Submitted leak should be accepted as a job, but got status %d", rec.Code)
+	}
+
+	j := decodeJobResponse(t, rec)
+
+	for deadline := time.Now().Add(5 * time.Second); j.State != job.Succeeded && j.State != job.Failed; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %d should finish, but it is still %s", j.Id, j.State)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%d", ImportsPath, j.Id), nil)
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+
+		j = decodeJobResponse(t, rec)
+	}
+
+	if j.State != job.Succeeded || j.LeakId != 7 || j.Records != 2 || j.Users != 1 || j.Errors != 1 {
+		t.Fatalf("Job should report the imported leak, but got %v", j)
+	}
+}
+
+func TestServerStreamsEventsOfSubmittedImportJob(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		return 7, nil
+	})
+
+	fields := map[string]string{
+		FormFieldContext:     "context",
+		FormFieldShareDateMS: "0",
+		FormFieldPlatforms:   "platform",
+		FormFieldLeakers:     "leaker",
+	}
+
+	j := decodeJobResponse(t, postLeakForm(server, ImportsPath, fields, "leak.txt", "a@example.com:password\n"))
+
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(fmt.Sprintf("%s%s/%d/%s", ts.URL, ImportsPath, j.Id, EventsPathSegment))
+
+	if err != nil {
+		t.Fatalf("Events of job %d should be streamed, but got %v", j.Id, err)
+	}
+
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Events should be streamed as text/event-stream, but got %s", ct)
+	}
+
+	var event string
+	var done JobResponse
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "event: ") {
+			event = strings.TrimPrefix(line, "event: ")
+		}
+
+		if event == DoneEvent && strings.HasPrefix(line, "data: ") {
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &done); err != nil {
+				t.Fatalf("Event data should be JSON, but got %v", err)
+			}
+		}
+	}
+
+	if event != DoneEvent || done.State != job.Succeeded || done.LeakId != 7 {
+		t.Fatalf("Stream should end with the done event of the imported leak, but got %s (%v)", event, done)
+	}
+}
+
+func TestServerDoesNotFindUnknownJob(t *testing.T) {
+	server := newTestImportServer(t, func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+		return 0, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, ImportsPath+"/42", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Job 42 was never submitted, but got status %d", rec.Code)
+	}
+}
+
+func newTestImportServer(t *testing.T, store importer.StoreImportFunc) *ImportServer {
+	im := importer.Importer{
+		DatabasePath: "leaksdb.sqlite",
+		Notifiers:    []importer.Notifier{importertest.Notifier{OnNotify: func(n importer.Notification) error { return nil }}},
+		Store:        store,
+	}
+
+	opts := importer.ParseOptions{
+		Format: parser.AutoFormat,
+		ErrorThreshold: importer.ErrorThreshold{
+			MaxErrors:     importer.DefaultMaxErrors,
+			MaxErrorRatio: importer.DefaultMaxErrorRatio,
+		},
+	}
+
+	jobs, err := job.OpenStore(filepath.Join(t.TempDir(), "jobs.sqlite"))
+
+	if err != nil {
+		panic(err)
+	}
+
+	t.Cleanup(func() { jobs.Close() })
+
+	queue := job.NewQueue(jobs, im, opts, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := queue.Start(ctx); err != nil {
+		panic(err)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+		queue.Wait()
+	})
+
+	return NewImportServer(im, opts, queue, DefaultMaxUploadSize)
+}
+
+func postLeakForm(server *ImportServer, path string, fields map[string]string, fileName string, content string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			panic(err)
+		}
+	}
+
+	fw, err := mw.CreateFormFile(FormFieldLeakFile, fileName)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := fw.Write([]byte(content)); err != nil {
+		panic(err)
+	}
+
+	if err := mw.Close(); err != nil {
+		panic(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	return rec
+}
+
+func decodeImportResponse(t *testing.T, rec *httptest.ResponseRecorder) ImportResponse {
+	var resp ImportResponse
+
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Response should be JSON, but got %v", err)
+	}
+
+	return resp
+}
+
+func decodeJobResponse(t *testing.T, rec *httptest.ResponseRecorder) JobResponse {
+	var resp JobResponse
+
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Response should be JSON, but got %v", err)
+	}
+
+	return resp
+}
@@ -0,0 +1,91 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+)
+
+// DuplicateImportError is returned when a leak with the same fingerprint was already imported as LeakId.
+type DuplicateImportError struct {
+	Fingerprint string
+	LeakId      entity.AutoGenKey
+}
+
+// fingerprint computes the SHA-256 of the input of a leak, which is the decompressed content of each of its sources
+// as it is parsed, with CRLF line endings normalized to LF. The fingerprint does not depend on how the leak file is
+// compressed or archived, but it does depend on every record, including passwords and lines that are rejected.
+type fingerprint struct {
+	hash hash.Hash
+	size int64
+	cr   bool
+}
+
+func newFingerprint() *fingerprint {
+	return &fingerprint{hash: sha256.New()}
+}
+
+func (e *DuplicateImportError) Error() string {
+	return fmt.Sprintf("Aborted import: the same leak was already imported as leak %d (fingerprint %s), force the import to store it again", e.LeakId, e.Fingerprint)
+}
+
+// Write adds b to the input. A CR at the end of b is held back until the next write, in case it is followed by a LF.
+func (f *fingerprint) Write(b []byte) (int, error) {
+	n := len(b)
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	f.size += int64(n)
+
+	if f.cr {
+		f.cr = false
+
+		if b[0] != '\n' {
+			f.hash.Write([]byte{'\r'})
+		}
+	}
+
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\r')
+
+		if i < 0 {
+			f.hash.Write(b)
+			break
+		}
+
+		f.hash.Write(b[:i])
+
+		if i == len(b)-1 {
+			f.cr = true
+			break
+		}
+
+		if b[i+1] != '\n' {
+			f.hash.Write(b[i : i+1])
+		}
+
+		b = b[i+1:]
+	}
+
+	return n, nil
+}
+
+// Returns the fingerprint as an hex string, which is empty if the leak has no input.
+func (f *fingerprint) sum() string {
+	if f.size == 0 {
+		return ""
+	}
+
+	if f.cr {
+		f.cr = false
+		f.hash.Write([]byte{'\r'})
+	}
+
+	return hex.EncodeToString(f.hash.Sum(nil))
+}
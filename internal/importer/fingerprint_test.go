@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/palavrapasse/import/internal/parser"
+)
+
+func TestFingerprintDependsOnPasswords(t *testing.T) {
+	f1 := readFingerprint(t, "leak.txt", []byte("a@example.com:password1\nb@example.com:password2\n"))
+	f2 := readFingerprint(t, "leak.txt", []byte("a@example.com:another1\nb@example.com:another2\n"))
+
+	if len(f1) != 64 || f1 == f2 {
+		t.Fatalf("Leaks of the same emails with different passwords should have different fingerprints, but got %s and %s", f1, f2)
+	}
+}
+
+func TestFingerprintDependsOnRejectedLines(t *testing.T) {
+	f1 := readFingerprint(t, "leak.txt", []byte("a@example.com:password1\nmalformed\n"))
+	f2 := readFingerprint(t, "leak.txt", []byte("a@example.com:password1\nanother malformed\n"))
+
+	if f1 == f2 {
+		t.Fatalf("Leaks with different rejected lines should have different fingerprints, but both got %s", f1)
+	}
+}
+
+func TestFingerprintDoesNotDependOnCompressionOrLineEndings(t *testing.T) {
+	var gz bytes.Buffer
+
+	w := gzip.NewWriter(&gz)
+
+	if _, err := w.Write([]byte("a@example.com:password1\nb@example.com:password2\n")); err != nil {
+		panic(err)
+	}
+
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+
+	f1 := readFingerprint(t, "leak.txt", []byte("a@example.com:password1\nb@example.com:password2\n"))
+	f2 := readFingerprint(t, "leak.txt.gz", gz.Bytes())
+	f3 := readFingerprint(t, "leak.txt", []byte("a@example.com:password1\r\nb@example.com:password2\r\n"))
+
+	if f1 != f2 || f1 != f3 {
+		t.Fatalf("Leaks with the same input should have the same fingerprint, but got %s, %s and %s", f1, f2, f3)
+	}
+}
+
+func TestFingerprintKeepsCarriageReturnsThatDoNotEndLines(t *testing.T) {
+	f1 := newFingerprint()
+	f2 := newFingerprint()
+
+	// The CR at the end of the first write is only dropped if the next write starts with a LF.
+	_, _ = f1.Write([]byte("a@example.com:pass\r"))
+	_, _ = f1.Write([]byte("word\r\n"))
+	_, _ = f2.Write([]byte("a@example.com:password\n"))
+
+	if f1.sum() == f2.sum() {
+		t.Fatalf("CR inside a record should be part of the fingerprint")
+	}
+}
+
+func TestFingerprintOfLeakWithoutInputIsEmpty(t *testing.T) {
+	if f := newFingerprint().sum(); len(f) != 0 {
+		t.Fatalf("Leak without input should not have a fingerprint, but got %s", f)
+	}
+}
+
+// Reads the leak stored in a file named name with content b, returning its fingerprint.
+func readFingerprint(t *testing.T, name string, b []byte) string {
+	fp := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(fp, b, 0600); err != nil {
+		panic(err)
+	}
+
+	leak := Leak{
+		ShareDate: time.Now(),
+		Path:      fp,
+		Context:   "context",
+		Platforms: []string{"platform"},
+		Leakers:   []string{"leaker"},
+	}
+
+	opts := ParseOptions{
+		Format:         parser.PlainTextFormat,
+		ErrorThreshold: ErrorThreshold{MaxErrors: DefaultMaxErrors, MaxErrorRatio: DefaultMaxErrorRatio},
+	}
+
+	lr, err := Read(context.Background(), leak, opts)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return lr.Fingerprint
+}
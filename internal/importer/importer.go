@@ -0,0 +1,604 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/logging"
+	"github.com/palavrapasse/import/internal/parser"
+)
+
+const MaxErrorLogCalls = 20000
+
+// Number of affected users that are stored at a time, by default.
+const DefaultBatchSize = 5000
+
+// Leak describes a leak file that is about to be imported. If Id is set, the file is another part of the stored
+// leak Id: its users, platforms and leakers are appended to it, and its context and share date are not used.
+type Leak struct {
+	ShareDate time.Time
+	Path      string
+	Context   string
+	Platforms []string
+	Leakers   []string
+	Id        entity.AutoGenKey
+}
+
+// ParseOptions configures how a leak file is parsed. OnProgress, if not nil, is called as the leak is read and
+// parsed, and OnUsers, if not nil, is called with each batch of parsed users.
+type ParseOptions struct {
+	Format         string
+	RejectsPath    string
+	Parser         parser.LeakParserOptions
+	ErrorThreshold ErrorThreshold
+	OnProgress     ProgressFunc
+	OnUsers        func(users query.LeakParse)
+}
+
+// LeakRead is a leak that was parsed and is ready to be stored. Its users are not kept in memory: they are parsed
+// again as they are stored. Users is the number of users of the leak, Records the number of its records, which are
+// its users and its errors about a single record, and ErrorCount the number of its errors, of which only the first
+// MaxErrorLogCalls are kept in Errors and the rest are only counted in ErrorReasons. Fingerprint identifies its
+// input.
+type LeakRead struct {
+	query.Import
+	Errors       []error
+	Format       string
+	Fingerprint  string
+	parser       parser.LeakParser
+	ErrorReasons map[parser.ParseErrorReason]int
+	Users        int
+	Records      int
+	ErrorCount   int
+}
+
+// StoreOptions configures how an import is stored. Affected users are streamed by Users, or taken from the
+// import if it is nil, and stored in batches of BatchSize. OnStored, if not nil, is called after each batch with the
+// number of users stored so far. The import is refused
+// with a DuplicateImportError if a leak with the same Fingerprint was already stored, unless Force is set. A
+// pending notification of the leak to each of NotifyTargets is stored along with the import, so that it is not lost
+// if it can't be delivered right away. If the import is appended to a stored leak, the users that it links to the leak
+// are recorded for its notifications, and OnAppended, if not nil, is called with the id of the appended part.
+type StoreOptions struct {
+	OnStored      func(users int)
+	OnAppended    func(appendId entity.AutoGenKey)
+	Users         UsersFunc
+	Fingerprint   string
+	NotifyTargets []string
+	BatchSize     int
+	Force         bool
+}
+
+// UsersFunc streams the affected users of an import, calling store with each batch of users as soon as it is
+// parsed. It stops at the first error that store returns, and returns it.
+type UsersFunc func(store func(users query.LeakParse) error) error
+
+// StoreImportFunc stores an import, stopping and rolling it back once ctx is done.
+type StoreImportFunc func(ctx context.Context, databasePath string, i query.Import, opts StoreOptions) (entity.AutoGenKey, error)
+
+// MarkNotifiedFunc marks the pending notifications of a stored leak to target as delivered: the one of its appended
+// part with appendId, or all of them if appendId is 0.
+type MarkNotifiedFunc func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, target string) error
+
+// DeliverPendingFunc delivers the pending notifications of the leaks stored in a database with the Notifiers that
+// newNotifier creates for their targets with opts, marking the ones that are delivered.
+type DeliverPendingFunc func(ctx context.Context, databasePath string, newNotifier NewNotifierFunc, opts NotifierOptions) error
+
+// Importer stores leaks in a database, BatchSize users at a time, and notifies each of Notifiers of each stored
+// leak, unless SkipNotify is set. Notifications are stored as pending along with the leak, and MarkNotified, if not
+// nil, marks each one as delivered once its target is notified. LoadNotification, if not nil, loads the
+// Notification of the leak, with the hashes of its affected users if NotifyHashes is set, otherwise notifications
+// only carry the leak id. Leaks that were already stored are refused, unless Force is set. OnProgress, if not nil,
+// is called as the leak is stored and notified.
+type Importer struct {
+	DatabasePath     string
+	Notifiers        []Notifier
+	Store            StoreImportFunc
+	MarkNotified     MarkNotifiedFunc
+	LoadNotification LoadNotificationFunc
+	OnProgress       ProgressFunc
+	BatchSize        int
+	Force            bool
+	SkipNotify       bool
+	NotifyHashes     bool
+}
+
+// Read validates the description of a leak and parses it, until ctx is done. The leak is streamed rather than
+// collected, so that only its counts, its fingerprint and its first errors are kept. Parse errors are logged, and a
+// ThresholdError is returned if they exceed the error threshold.
+func Read(ctx context.Context, l Leak, opts ParseOptions) (LeakRead, error) {
+	progress := newProgressTracker(opts.OnProgress)
+	progress.phase(ReadingPhase)
+
+	i, err := NewImport(l)
+
+	if err != nil {
+		return LeakRead{}, err
+	}
+
+	if err := opts.ErrorThreshold.Validate(); err != nil {
+		return LeakRead{}, err
+	}
+
+	parserOptions := opts.Parser
+	parserOptions.FilePath = l.Path
+	parserOptions.Separator = parser.UnescapeSeparator(parserOptions.Separator)
+	parserOptions.OnProgress = progress.parsed
+
+	fp := newFingerprint()
+	parserOptions.Input = fp
+
+	p, format, err := NewLeakParser(opts.Format, parserOptions)
+
+	if err != nil {
+		return LeakRead{}, err
+	}
+
+	lr := LeakRead{
+		Import:       i,
+		Format:       format,
+		ErrorReasons: map[parser.ParseErrorReason]int{},
+	}
+
+	err = parseLeak(ctx, p, opts.RejectsPath, func(b parser.LeakParseBatch) error {
+		lr.add(b)
+
+		if opts.OnUsers != nil {
+			opts.OnUsers(b.LeakParse)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return LeakRead{}, err
+	}
+
+	if lr.ErrorCount != 0 {
+		if lr.ErrorCount > MaxErrorLogCalls {
+			logging.Aspirador.Warning(fmt.Sprintf("Found a lot of errors during leak parse (%d)...", lr.ErrorCount))
+
+			LogParseErrorsSummary(lr.ErrorReasons)
+		} else {
+			logging.Aspirador.Warning("Found the following errors parsing leak:")
+
+			for _, v := range lr.Errors {
+				logging.Aspirador.Warning(v.Error())
+			}
+		}
+
+		if err := opts.ErrorThreshold.Check(lr.ErrorCount, lr.Users); err != nil {
+			LogParseErrorsSummary(lr.ErrorReasons)
+			return LeakRead{}, err
+		}
+	}
+
+	// The users are parsed again as they are stored, without reporting progress or computing the fingerprint.
+	parserOptions.OnProgress = nil
+	parserOptions.Input = nil
+
+	if lr.parser, err = parser.NewLeakParser(format, parserOptions); err != nil {
+		return LeakRead{}, err
+	}
+
+	lr.Fingerprint = fp.sum()
+
+	return lr, nil
+}
+
+// NewImport creates an import without affected users, validating the description of the leak. If the leak has
+// an id, the import is appended to the stored leak, so only its path is required.
+func NewImport(l Leak) (query.Import, error) {
+	if l.Id != 0 {
+		return newAppendImport(l)
+	}
+
+	var errors []error
+
+	err := validateNonEmptyValue(l.Path, "leak path")
+	errors = appendValidError(errors, err)
+
+	err = validateNonEmptyValue(l.Context, "context")
+	errors = appendValidError(errors, err)
+
+	err = validateValues(l.Platforms, "platforms")
+	errors = appendValidError(errors, err)
+
+	err = validateValues(l.Leakers, "leakers")
+	errors = appendValidError(errors, err)
+
+	if l.ShareDate.IsZero() {
+		errors = append(errors, fmt.Errorf("share date should not be empty"))
+	}
+
+	if len(errors) != 0 {
+		return query.Import{}, errors[0]
+	}
+
+	leakPlatforms, err := createPlatforms(l.Platforms)
+	errors = appendValidError(errors, err)
+
+	leakBadActors, err := createBadActors(l.Leakers)
+	errors = appendValidError(errors, err)
+
+	shareDateFormat := l.ShareDate.Format(query.DateFormatLayout)
+	sharedatesc, err := query.NewDateInSeconds(shareDateFormat)
+	errors = appendValidError(errors, err)
+
+	if len(errors) != 0 {
+		return query.Import{}, errors[0]
+	}
+
+	leak, err := query.NewLeak(l.Context, sharedatesc)
+
+	if err != nil {
+		return query.Import{}, err
+	}
+
+	return query.Import{
+		Leak:              leak,
+		AffectedPlatforms: leakPlatforms,
+		Leakers:           leakBadActors,
+	}, nil
+}
+
+func newAppendImport(l Leak) (query.Import, error) {
+	if err := validateNonEmptyValue(l.Path, "leak path"); err != nil {
+		return query.Import{}, err
+	}
+
+	leakPlatforms, err := createPlatforms(l.Platforms)
+
+	if err != nil {
+		return query.Import{}, err
+	}
+
+	leakBadActors, err := createBadActors(l.Leakers)
+
+	if err != nil {
+		return query.Import{}, err
+	}
+
+	return query.Import{
+		Leak:              query.Leak{LeakId: l.Id},
+		AffectedPlatforms: leakPlatforms,
+		Leakers:           leakBadActors,
+	}, nil
+}
+
+// NewLeakParser creates the leak parser of format, detecting it if format is auto. Returns the parser along with
+// its format.
+func NewLeakParser(format string, opts parser.LeakParserOptions) (parser.LeakParser, string, error) {
+	if format == parser.AutoFormat {
+		detections, err := parser.DetectFormat(opts.FilePath, opts.Include)
+
+		if err != nil {
+			return nil, format, err
+		}
+
+		for _, d := range detections {
+			logging.Aspirador.Trace(fmt.Sprintf("Format %s detected with %.2f confidence", d.Format, d.Confidence))
+		}
+
+		best := detections[0]
+
+		if best.Confidence == 0 {
+			best.Format = parser.PlainTextFormat
+			logging.Aspirador.Warning(fmt.Sprintf("Could not detect leak format, falling back to %s", best.Format))
+		} else {
+			logging.Aspirador.Info(fmt.Sprintf("Detected %s leak format (confidence %.2f)", best.Format, best.Confidence))
+		}
+
+		format = best.Format
+	}
+
+	p, err := parser.NewLeakParser(format, opts)
+
+	return p, format, err
+}
+
+// LogParseErrorsSummary logs how many parse errors were found of each reason in counts.
+func LogParseErrorsSummary(counts map[parser.ParseErrorReason]int) {
+	for _, r := range parser.ParseErrorReasons() {
+		if counts[r] != 0 {
+			logging.Aspirador.Warning(fmt.Sprintf("%s: %d", r, counts[r]))
+		}
+	}
+}
+
+// Import stores a parsed leak and notifies the service of it, until ctx is done. If the leak is appended to a stored
+// leak, the notification is only about the users that it linked to the stored leak.
+func (im Importer) Import(ctx context.Context, lr LeakRead) (entity.AutoGenKey, error) {
+	progress := newProgressTracker(im.OnProgress)
+	progress.progress.Records = lr.Records
+	progress.progress.UsersTotal = lr.Users
+	progress.phase(StoringPhase)
+
+	opts := StoreOptions{
+		OnStored:    progress.stored,
+		Users:       lr.users(ctx),
+		Fingerprint: lr.Fingerprint,
+		BatchSize:   im.BatchSize,
+		Force:       im.Force,
+	}
+
+	var appendId entity.AutoGenKey
+
+	opts.OnAppended = func(id entity.AutoGenKey) {
+		appendId = id
+	}
+
+	if !im.SkipNotify {
+		for _, n := range im.Notifiers {
+			opts.NotifyTargets = append(opts.NotifyTargets, n.Target())
+		}
+	}
+
+	leakId, err := im.Store(ctx, im.DatabasePath, lr.Import, opts)
+
+	if err != nil {
+		return leakId, err
+	}
+
+	logging.Aspirador.Info(fmt.Sprintf("Successful Import (%d)", lr.Users))
+
+	progress.progress.Users = lr.Users
+
+	if im.SkipNotify {
+		logging.Aspirador.Info(fmt.Sprintf("Skipped notification of leak %d", leakId))
+		return leakId, nil
+	}
+
+	if len(im.Notifiers) == 0 {
+		return leakId, nil
+	}
+
+	progress.phase(NotifyingPhase)
+
+	return leakId, im.notify(ctx, leakId, appendId)
+}
+
+// NotifyLeak notifies each of Notifiers of the stored leak with leakId, and marks the notification of each target
+// that is notified as delivered. A NotificationError is returned if any target could not be notified, and it stops
+// once ctx is done.
+func (im Importer) NotifyLeak(ctx context.Context, leakId entity.AutoGenKey) error {
+	return im.notify(ctx, leakId, 0)
+}
+
+// Notifies each of Notifiers of the appended part with appendId of the stored leak with leakId, or of the whole leak
+// if appendId is 0.
+func (im Importer) notify(ctx context.Context, leakId entity.AutoGenKey, appendId entity.AutoGenKey) error {
+	notification := Notification{Version: NotificationVersion, LeakId: leakId, Appended: appendId != 0}
+
+	if im.LoadNotification != nil {
+		var err error
+
+		notification, err = im.LoadNotification(ctx, im.DatabasePath, leakId, appendId, im.NotifyHashes)
+
+		if err != nil {
+			logging.Aspirador.Warning(fmt.Sprintf("Could not load notification of leak %d: %v", leakId, err))
+
+			return &NotificationError{Err: err, Failed: len(im.Notifiers), Total: len(im.Notifiers)}
+		}
+	}
+
+	failed := 0
+
+	var lastErr error
+
+	for _, n := range im.Notifiers {
+		if err := n.Notify(ctx, notification); err != nil {
+			logging.Aspirador.Warning(fmt.Sprintf("Notification of leak %d to %s failed: %v", leakId, n.Target(), err))
+
+			failed++
+			lastErr = err
+
+			if ctx.Err() != nil {
+				break
+			}
+
+			continue
+		}
+
+		if im.MarkNotified == nil {
+			continue
+		}
+
+		// The target was notified, so failing to mark the notification only means it will be delivered again.
+		if err := im.MarkNotified(ctx, im.DatabasePath, leakId, appendId, n.Target()); err != nil {
+			logging.Aspirador.Warning(fmt.Sprintf("Notification of leak %d to %s was delivered, but is still pending: %v", leakId, n.Target(), err))
+		}
+	}
+
+	if lastErr == nil {
+		return nil
+	}
+
+	if im.MarkNotified != nil {
+		logging.Aspirador.Warning(fmt.Sprintf("Notification of leak %d is pending and can be delivered later", leakId))
+	}
+
+	return &NotificationError{Err: lastErr, Failed: failed, Total: len(im.Notifiers)}
+}
+
+// SerializeStore returns a StoreImportFunc that stores one import at a time, so that leaks that are imported
+// concurrently do not compete for the same database.
+func SerializeStore(store StoreImportFunc) StoreImportFunc {
+	var mutex sync.Mutex
+
+	return func(ctx context.Context, databasePath string, i query.Import, opts StoreOptions) (entity.AutoGenKey, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return store(ctx, databasePath, i, opts)
+	}
+}
+
+// Adds a batch of the leak to its counts, keeping its first errors.
+func (lr *LeakRead) add(b parser.LeakParseBatch) {
+	lr.Users += len(b.LeakParse)
+	lr.Records += len(b.LeakParse)
+	lr.ErrorCount += len(b.Errors)
+
+	for _, err := range b.Errors {
+		var perr *parser.ParseError
+
+		if errors.As(err, &perr) {
+			lr.Records++
+		}
+
+		if len(lr.Errors) < MaxErrorLogCalls {
+			lr.Errors = append(lr.Errors, err)
+		}
+	}
+
+	for r, n := range parser.CountParseErrors(b.Errors) {
+		lr.ErrorReasons[r] += n
+	}
+}
+
+// Returns a UsersFunc that parses the leak again until ctx is done, or nil if the users of the leak are in its
+// import. An error is returned if the leak does not have the same number of users it had when it was read.
+func (lr LeakRead) users(ctx context.Context) UsersFunc {
+	if lr.parser == nil {
+		return nil
+	}
+
+	return func(store func(users query.LeakParse) error) error {
+		users := 0
+
+		err := lr.parser.ParseBatches(ctx, func(b parser.LeakParseBatch) error {
+			users += len(b.LeakParse)
+
+			return store(b.LeakParse)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("parse of leak was interrupted: %w", err)
+		}
+
+		if users != lr.Users {
+			return fmt.Errorf("leak changed since it was read: it had %d users, but now has %d", lr.Users, users)
+		}
+
+		return nil
+	}
+}
+
+// Parses the leak, handing each batch to bcb and writing rejected lines to rejectsPath if it is not empty. Returns
+// an error if ctx is done before the leak is fully parsed.
+func parseLeak(ctx context.Context, p parser.LeakParser, rejectsPath string, bcb parser.OnLeakParseBatchCallback) error {
+	if len(strings.TrimSpace(rejectsPath)) == 0 {
+		if err := p.ParseBatches(ctx, bcb); err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("parse of leak was interrupted: %w", err)
+		}
+
+		return nil
+	}
+
+	f, err := os.Create(rejectsPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	rw, err := parser.NewRejectsWriter(f, parser.RejectsFormatOfPath(rejectsPath))
+
+	if err != nil {
+		return err
+	}
+
+	if err := p.ParseBatches(ctx, bcb, rw.OnParseError); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("parse of leak was interrupted: %w", err)
+	}
+
+	if err := rw.Flush(); err != nil {
+		return fmt.Errorf("could not write rejected lines to %s: %w", rejectsPath, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	logging.Aspirador.Info(fmt.Sprintf("Wrote %d rejected lines to %s", rw.Count(), rejectsPath))
+
+	return nil
+}
+
+func createPlatforms(platforms []string) ([]query.Platform, error) {
+	var list []query.Platform
+
+	for _, v := range platforms {
+		platform, err := query.NewPlatform(v)
+
+		if err != nil {
+			return list, err
+		}
+
+		list = append(list, platform)
+	}
+
+	return list, nil
+}
+
+func createBadActors(leakers []string) ([]query.BadActor, error) {
+	var list []query.BadActor
+
+	for _, v := range leakers {
+		badActor, err := query.NewBadActor(v)
+
+		if err != nil {
+			return list, err
+		}
+
+		list = append(list, badActor)
+	}
+
+	return list, nil
+}
+
+func validateNonEmptyValue(value string, name string) error {
+	if len(strings.TrimSpace(value)) == 0 {
+		return fmt.Errorf("%s should not be empty or only white spaces", name)
+	}
+
+	return nil
+}
+
+func validateValues(value []string, name string) error {
+	if len(value) == 0 {
+		return fmt.Errorf("%s should not be empty", name)
+	}
+
+	return nil
+}
+
+func appendValidError(errors []error, err error) []error {
+
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	return errors
+}
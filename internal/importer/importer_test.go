@@ -0,0 +1,212 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/importer/importertest"
+)
+
+func TestNewImportOfStoredLeakOnlyRequiresPath(t *testing.T) {
+	i, err := importer.NewImport(importer.Leak{Id: 7, Path: "part2.txt", Platforms: []string{"platform"}})
+
+	if err != nil {
+		t.Fatalf("Part of a stored leak should only require its path, but got %v", err)
+	}
+
+	if i.Leak.LeakId != 7 || len(i.AffectedPlatforms) != 1 || len(i.Leakers) != 0 {
+		t.Fatalf("Import should be appended to leak 7 with its platforms, but got %v", i)
+	}
+}
+
+func TestNewImportOfStoredLeakRequiresPath(t *testing.T) {
+	if _, err := importer.NewImport(importer.Leak{Id: 7}); err == nil {
+		t.Fatalf("Part of a stored leak without a path should not be imported")
+	}
+}
+
+func TestImportSkipsNotification(t *testing.T) {
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			if len(opts.NotifyTargets) != 0 {
+				t.Fatalf("Skipped notification should not be stored as pending, but got %v", opts.NotifyTargets)
+			}
+
+			return 7, nil
+		},
+		Notifiers: []importer.Notifier{importertest.Notifier{Destination: "https://subscribeService/notify", OnNotify: func(n importer.Notification) error {
+			t.Fatalf("Leak %d should not be notified", n.LeakId)
+			return nil
+		}}},
+		SkipNotify: true,
+	}
+
+	if leakId, err := im.Import(context.Background(), importer.LeakRead{}); err != nil || leakId != 7 {
+		t.Fatalf("Leak should be imported as leak 7, but got %d (%v)", leakId, err)
+	}
+}
+
+func TestImportWithoutNotifiersDoesNotNotify(t *testing.T) {
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			return 7, nil
+		},
+	}
+
+	if leakId, err := im.Import(context.Background(), importer.LeakRead{}); err != nil || leakId != 7 {
+		t.Fatalf("Leak without notification targets should be imported as leak 7, but got %d (%v)", leakId, err)
+	}
+}
+
+func TestImportMarksDeliveredNotifications(t *testing.T) {
+	var marked []string
+
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			if len(opts.NotifyTargets) != 2 {
+				t.Fatalf("Notifications of both targets should be stored as pending with the import, but got %v", opts.NotifyTargets)
+			}
+
+			return 7, nil
+		},
+		Notifiers: []importer.Notifier{
+			importertest.Notifier{Destination: "https://subscribeService/notify", OnNotify: func(n importer.Notification) error { return nil }},
+			importertest.Notifier{Destination: "exec:/usr/local/bin/on-leak", OnNotify: func(n importer.Notification) error { return nil }},
+		},
+		MarkNotified: func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, target string) error {
+			marked = append(marked, target)
+			return nil
+		},
+	}
+
+	if _, err := im.Import(context.Background(), importer.LeakRead{}); err != nil || len(marked) != 2 {
+		t.Fatalf("Notifications of leak 7 to both targets should be marked as delivered, but got %v (%v)", marked, err)
+	}
+}
+
+func TestImportKeepsFailedNotificationPending(t *testing.T) {
+	var marked []string
+
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			return 7, nil
+		},
+		Notifiers: []importer.Notifier{
+			importertest.Notifier{Destination: "https://subscribeService/notify", OnNotify: func(n importer.Notification) error {
+				return errors.New("service is down")
+			}},
+			importertest.Notifier{Destination: "exec:/usr/local/bin/on-leak", OnNotify: func(n importer.Notification) error { return nil }},
+		},
+		MarkNotified: func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, target string) error {
+			marked = append(marked, target)
+			return nil
+		},
+	}
+
+	leakId, err := im.Import(context.Background(), importer.LeakRead{})
+
+	var nerr *importer.NotificationError
+
+	if !errors.As(err, &nerr) || nerr.Failed != 1 || nerr.Total != 2 || leakId != 7 {
+		t.Fatalf("Failed notification of leak 7 should be reported, but got %d (%v)", leakId, err)
+	}
+
+	if len(marked) != 1 || marked[0] != "exec:/usr/local/bin/on-leak" {
+		t.Fatalf("Only the notification that was delivered should be marked, but got %v", marked)
+	}
+}
+
+func TestImportNotifiesLoadedNotification(t *testing.T) {
+	var notified []importer.Notification
+
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			return 7, nil
+		},
+		Notifiers: []importer.Notifier{
+			importertest.Notifier{Destination: "https://subscribeService/notify", OnNotify: func(n importer.Notification) error {
+				notified = append(notified, n)
+				return nil
+			}},
+		},
+		LoadNotification: func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, hashes bool) (importer.Notification, error) {
+			if !hashes {
+				t.Fatalf("Notification of leak %d should be loaded with the hashes of its affected users", leakId)
+			}
+
+			return importer.Notification{Version: importer.NotificationVersion, LeakId: leakId, Context: "context", AffectedUsers: 2}, nil
+		},
+		NotifyHashes: true,
+	}
+
+	if _, err := im.Import(context.Background(), importer.LeakRead{}); err != nil {
+		t.Fatalf("Leak 7 should be imported and notified, but got %v", err)
+	}
+
+	if len(notified) != 1 || notified[0].LeakId != 7 || notified[0].Context != "context" || notified[0].AffectedUsers != 2 {
+		t.Fatalf("Target should be notified with the loaded notification of leak 7, but got %v", notified)
+	}
+}
+
+func TestImportOfAppendedPartOnlyNotifiesPart(t *testing.T) {
+	var loaded, marked []entity.AutoGenKey
+
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			opts.OnAppended(3)
+			return i.Leak.LeakId, nil
+		},
+		Notifiers: []importer.Notifier{
+			importertest.Notifier{Destination: "https://subscribeService/notify", OnNotify: func(n importer.Notification) error { return nil }},
+		},
+		LoadNotification: func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, hashes bool) (importer.Notification, error) {
+			loaded = append(loaded, appendId)
+			return importer.Notification{Version: importer.NotificationVersion, LeakId: leakId, Appended: true}, nil
+		},
+		MarkNotified: func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, target string) error {
+			marked = append(marked, appendId)
+			return nil
+		},
+	}
+
+	lr := importer.LeakRead{Import: query.Import{Leak: query.Leak{LeakId: 7}}}
+
+	if _, err := im.Import(context.Background(), lr); err != nil {
+		t.Fatalf("Part should be appended to leak 7 and notified, but got %v", err)
+	}
+
+	if len(loaded) != 1 || loaded[0] != 3 || len(marked) != 1 || marked[0] != 3 {
+		t.Fatalf("Only the notification of appended part 3 should be loaded and marked, but got %v and %v", loaded, marked)
+	}
+}
+
+func TestImportKeepsNotificationPendingIfItCannotBeLoaded(t *testing.T) {
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			return 7, nil
+		},
+		Notifiers: []importer.Notifier{
+			importertest.Notifier{Destination: "https://subscribeService/notify", OnNotify: func(n importer.Notification) error {
+				t.Fatalf("Leak %d should not be notified without its notification", n.LeakId)
+				return nil
+			}},
+		},
+		LoadNotification: func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, hashes bool) (importer.Notification, error) {
+			return importer.Notification{}, errors.New("database is locked")
+		},
+		MarkNotified: func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, target string) error {
+			t.Fatalf("Notification of leak %d to %s should stay pending", leakId, target)
+			return nil
+		},
+	}
+
+	var nerr *importer.NotificationError
+
+	if _, err := im.Import(context.Background(), importer.LeakRead{}); !errors.As(err, &nerr) || nerr.Failed != 1 {
+		t.Fatalf("Notification that could not be loaded should be reported as failed, but got %v", err)
+	}
+}
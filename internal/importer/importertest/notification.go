@@ -0,0 +1,33 @@
+// Package importertest provides fixtures of the importer package, shared by the tests of the notifiers of its
+// targets.
+package importertest
+
+import (
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+// NewNotification returns the notification of the stored leak with leakId that tests deliver.
+func NewNotification(leakId entity.AutoGenKey) importer.Notification {
+	return importer.Notification{
+		Version:       importer.NotificationVersion,
+		LeakId:        leakId,
+		Context:       "context",
+		ShareDate:     "2023-01-02",
+		Platforms:     []string{"platform"},
+		Leakers:       []string{"leaker"},
+		AffectedUsers: 2,
+		Domains:       map[string]int{"example.com": 2},
+	}
+}
+
+// Payload returns the payload of the notification of leakId that tests deliver.
+func Payload(leakId entity.AutoGenKey) string {
+	b, err := NewNotification(leakId).Payload()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,35 @@
+package importertest
+
+import (
+	"context"
+
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+// DefaultTarget is the target of a Notifier whose Destination is empty.
+const DefaultTarget = "https://subscribeService/notify"
+
+// Notifier is a notifier of tests, which calls OnNotify with each notification that it delivers to Destination.
+type Notifier struct {
+	OnNotify    func(n importer.Notification) error
+	Destination string
+}
+
+// NewNotifierFunc returns a NewNotifierFunc that creates a Notifier of each target, which calls onNotify.
+func NewNotifierFunc(onNotify func(n importer.Notification) error) importer.NewNotifierFunc {
+	return func(target string, opts importer.NotifierOptions) (importer.Notifier, error) {
+		return Notifier{OnNotify: onNotify, Destination: target}, nil
+	}
+}
+
+func (n Notifier) Notify(ctx context.Context, notification importer.Notification) error {
+	return n.OnNotify(notification)
+}
+
+func (n Notifier) Target() string {
+	if len(n.Destination) == 0 {
+		return DefaultTarget
+	}
+
+	return n.Destination
+}
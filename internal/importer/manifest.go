@@ -0,0 +1,147 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"gopkg.in/yaml.v3"
+)
+
+const manifestListSeparator = ","
+
+// Manifest describes a batch of leaks to be imported into the same database. It has the same shape as the
+// args.json file of import-bash, and can be written either in JSON or in YAML. Notify lists the targets to notify
+// of each leak, in addition to NotifyURL.
+type Manifest struct {
+	DatabasePath string          `json:"leaksdb_fp" yaml:"leaksdb_fp"` //nolint:tagliatelle // Same shape as args.json.
+	NotifyURL    string          `json:"notify_url" yaml:"notify_url"` //nolint:tagliatelle // Same shape as args.json.
+	Notify       []string        `json:"notify" yaml:"notify"`
+	Leaks        []ManifestEntry `json:"leaks" yaml:"leaks"`
+}
+
+// ManifestEntry describes a single leak of a manifest. Platforms and leakers can either be a list or a string
+// separated by commas.
+type ManifestEntry struct {
+	LeakPath  string       `json:"leak_fp" yaml:"leak_fp"`       //nolint:tagliatelle // Same shape as args.json.
+	ShareDate string       `json:"share_date" yaml:"share_date"` //nolint:tagliatelle // Same shape as args.json.
+	Context   string       `json:"context" yaml:"context"`
+	Platforms manifestList `json:"platforms" yaml:"platforms"`
+	Leakers   manifestList `json:"leakers" yaml:"leakers"`
+}
+
+type manifestList []string
+
+// LoadManifest reads a manifest file, which is parsed as YAML if it has a YAML extension and as JSON otherwise.
+func LoadManifest(path string) (Manifest, error) {
+	var m Manifest
+
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		return m, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &m)
+	default:
+		err = json.Unmarshal(b, &m)
+	}
+
+	if err != nil {
+		return m, fmt.Errorf("could not read manifest %s: %w", path, err)
+	}
+
+	if len(m.Leaks) == 0 {
+		return m, fmt.Errorf("manifest %s does not contain any leak", path)
+	}
+
+	return m, nil
+}
+
+// NotifyTargets returns the targets to notify of each leak of the manifest.
+func (m Manifest) NotifyTargets() []string {
+	if len(strings.TrimSpace(m.NotifyURL)) == 0 {
+		return m.Notify
+	}
+
+	return append([]string{m.NotifyURL}, m.Notify...)
+}
+
+// Leak returns the description of the leak of the entry.
+func (e ManifestEntry) Leak() (Leak, error) {
+	leak := Leak{
+		Path:      e.LeakPath,
+		Context:   e.Context,
+		Platforms: e.Platforms,
+		Leakers:   e.Leakers,
+	}
+
+	if len(strings.TrimSpace(e.ShareDate)) == 0 {
+		return leak, nil
+	}
+
+	shareDate, err := time.Parse(query.DateFormatLayout, strings.TrimSpace(e.ShareDate))
+
+	if err != nil {
+		return leak, fmt.Errorf("share date of %s should follow the %s layout: %w", e.LeakPath, query.DateFormatLayout, err)
+	}
+
+	leak.ShareDate = shareDate
+
+	return leak, nil
+}
+
+func (l *manifestList) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = SplitList(s)
+		return nil
+	}
+
+	var values []string
+
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+
+	*l = values
+
+	return nil
+}
+
+func (l *manifestList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = SplitList(node.Value)
+		return nil
+	}
+
+	var values []string
+
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+
+	*l = values
+
+	return nil
+}
+
+// SplitList splits a list of values separated by commas, ignoring empty values.
+func SplitList(s string) []string {
+	var values []string
+
+	for _, v := range strings.Split(s, manifestListSeparator) {
+		if v = strings.TrimSpace(v); len(v) != 0 {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
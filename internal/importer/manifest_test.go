@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const jsonManifest = `{
+    "leaksdb_fp": "/usr/share/palavrapasse/leaksdb.sqlite",
+    "notify_url": "https://subscribeService/notify",
+    "leaks": [
+        {
+            "leak_fp": "/usr/share/palavrapasse/leak.txt",
+            "context": "context",
+            "platforms": "platform1, platform2",
+            "share_date": "1970-01-01",
+            "leakers": "UNKNOWN"
+        }
+    ]
+}`
+
+const yamlManifest = `leaksdb_fp: /usr/share/palavrapasse/leaksdb.sqlite
+notify_url: https://subscribeService/notify
+notify: ["exec:/usr/local/bin/on-leak"]
+leaks:
+  - leak_fp: /usr/share/palavrapasse/leak.txt
+    context: context
+    platforms: [platform1, platform2]
+    share_date: "1970-01-01"
+    leakers: UNKNOWN
+`
+
+func TestCanLoadJSONManifest(t *testing.T) {
+	assertManifestLoads(t, writeManifest(t, "leaks.json", jsonManifest))
+}
+
+func TestCanLoadYAMLManifest(t *testing.T) {
+	assertManifestLoads(t, writeManifest(t, "leaks.yaml", yamlManifest))
+}
+
+func TestCannotLoadManifestWithoutLeaks(t *testing.T) {
+	_, err := LoadManifest(writeManifest(t, "leaks.json", `{"leaksdb_fp": "leaksdb.sqlite", "leaks": []}`))
+
+	if err == nil {
+		t.Fatalf("Manifest does not contain any leak, but no error was identified")
+	}
+}
+
+func TestCannotCreateLeakOfEntryWithInvalidShareDate(t *testing.T) {
+	_, err := ManifestEntry{LeakPath: "leak.txt", ShareDate: "01/01/1970"}.Leak()
+
+	if err == nil {
+		t.Fatalf("Share date 01/01/1970 does not follow the date layout, but no error was identified")
+	}
+}
+
+func TestManifestNotifiesNotifyURLAndNotifyTargets(t *testing.T) {
+	m, err := LoadManifest(writeManifest(t, "leaks.yaml", yamlManifest))
+
+	if err != nil {
+		panic(err)
+	}
+
+	if targets := m.NotifyTargets(); len(targets) != 2 || targets[0] != m.NotifyURL || targets[1] != "exec:/usr/local/bin/on-leak" {
+		t.Fatalf("Manifest should notify its notify_url and notify targets, but got %v", targets)
+	}
+}
+
+func writeManifest(t *testing.T, name string, content string) string {
+	fp := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(fp, []byte(content), 0600); err != nil {
+		panic(err)
+	}
+
+	return fp
+}
+
+func assertManifestLoads(t *testing.T, fp string) {
+	m, err := LoadManifest(fp)
+
+	if err != nil {
+		t.Fatalf("Manifest %s should load, but got %v", filepath.Base(fp), err)
+	}
+
+	if m.DatabasePath != "/usr/share/palavrapasse/leaksdb.sqlite" || m.NotifyURL != "https://subscribeService/notify" || len(m.Leaks) != 1 {
+		t.Fatalf("Manifest %s was not loaded as expected: %v", filepath.Base(fp), m)
+	}
+
+	leak, err := m.Leaks[0].Leak()
+
+	if err != nil {
+		t.Fatalf("Manifest %s entry should be a valid leak, but got %v", filepath.Base(fp), err)
+	}
+
+	if len(leak.Platforms) != 2 || leak.Platforms[1] != "platform2" || len(leak.Leakers) != 1 {
+		t.Fatalf("Manifest %s entry lists should be split by commas, but got %v and %v", filepath.Base(fp), leak.Platforms, leak.Leakers)
+	}
+
+	if leak.ShareDate.Year() != 1970 {
+		t.Fatalf("Manifest %s entry share date should be 1970-01-01, but got %v", filepath.Base(fp), leak.ShareDate)
+	}
+}
@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+)
+
+// NotificationVersion is the version of the Notification payload. Version 1 only carried the leak id.
+const NotificationVersion = 2
+
+// Notifier notifies a target, such as a service or a local script, of stored leaks. Target returns the target the
+// Notifier was created for, so that a NewNotifierFunc can create it again to deliver pending notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+	Target() string
+}
+
+// NotifierOptions configures the Notifiers of targets. Webhooks sign their notifications with Secret, unless it is
+// empty. Notifications carry the hashes of the emails of affected users if Hashes is set.
+type NotifierOptions struct {
+	Secret string
+	Hashes bool
+}
+
+// Notification is the JSON payload that notifies a target of a stored leak, so that it does not need to look the leak
+// up. Domains counts the affected users of each email domain, and Hashes, if requested, lists the SHA-256 hashes of
+// their emails. If Appended is set, the notification is about parts appended to the leak, and its affected users are
+// only the ones that those parts linked to the leak.
+type Notification struct {
+	Domains       map[string]int    `json:"domains"`
+	Context       string            `json:"context"`
+	ShareDate     string            `json:"shareDate"`
+	Platforms     []string          `json:"platforms"`
+	Leakers       []string          `json:"leakers"`
+	Hashes        []string          `json:"hashes,omitempty"`
+	Version       int               `json:"version"`
+	LeakId        entity.AutoGenKey `json:"leakId"`
+	AffectedUsers int               `json:"affectedUsers"`
+	Appended      bool              `json:"appended"`
+}
+
+// LoadNotificationFunc returns the Notification of the stored leak with leakId, along with the hashes of the emails
+// of its affected users if hashes is set. If appendId is not 0, the notification is about the appended part with
+// appendId, otherwise it is about the whole leak.
+type LoadNotificationFunc func(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, hashes bool) (Notification, error)
+
+// NewNotifierFunc returns the Notifier of a target, configured with opts.
+type NewNotifierFunc func(target string, opts NotifierOptions) (Notifier, error)
+
+// NotificationError is returned when some of the notifications of stored leaks could not be delivered. Err is the
+// error of the last notification that failed. Notifications that failed stay pending.
+type NotificationError struct {
+	Err    error
+	Failed int
+	Total  int
+}
+
+// NewNotifiers returns the Notifiers of targets, configured with opts.
+func NewNotifiers(targets []string, opts NotifierOptions, newNotifier NewNotifierFunc) ([]Notifier, error) {
+	notifiers := make([]Notifier, 0, len(targets))
+
+	for _, t := range targets {
+		n, err := newNotifier(t, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		notifiers = append(notifiers, n)
+	}
+
+	return notifiers, nil
+}
+
+// Payload returns the JSON encoding of the notification.
+func (n Notification) Payload() ([]byte, error) {
+	return json.Marshal(n)
+}
+
+func (e *NotificationError) Error() string {
+	return fmt.Sprintf("could not deliver %d of %d notifications: %v", e.Failed, e.Total, e.Err)
+}
+
+func (e *NotificationError) Unwrap() error {
+	return e.Err
+}
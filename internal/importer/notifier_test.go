@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"testing"
+)
+
+func TestNotificationPayload(t *testing.T) {
+	n := Notification{
+		Version:       NotificationVersion,
+		LeakId:        7,
+		Context:       "context",
+		ShareDate:     "2023-01-02",
+		Platforms:     []string{"platform"},
+		Leakers:       []string{"leaker"},
+		AffectedUsers: 3,
+		Domains:       map[string]int{"example.org": 1, "example.com": 2},
+	}
+
+	b, err := n.Payload()
+
+	if err != nil {
+		t.Fatalf("Notification should be encoded, but got %v", err)
+	}
+
+	want := `{"domains":{"example.com":2,"example.org":1},"context":"context","shareDate":"2023-01-02","platforms":["platform"],"leakers":["leaker"],"version":2,"leakId":7,"affectedUsers":3,"appended":false}`
+
+	if string(b) != want {
+		t.Fatalf("Notification without hashes should be encoded as %s, but got %s", want, b)
+	}
+
+	n.Hashes = []string{"hash"}
+
+	want = `{"domains":{"example.com":2,"example.org":1},"context":"context","shareDate":"2023-01-02","platforms":["platform"],"leakers":["leaker"],"hashes":["hash"],"version":2,"leakId":7,"affectedUsers":3,"appended":false}`
+
+	if b, err := n.Payload(); err != nil || string(b) != want {
+		t.Fatalf("Notification with hashes should be encoded as %s, but got %s (%v)", want, b, err)
+	}
+}
@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"time"
+
+	"github.com/palavrapasse/import/internal/parser"
+)
+
+const (
+	ReadingPhase   Phase = "reading"
+	ParsingPhase   Phase = "parsing"
+	StoringPhase   Phase = "storing"
+	NotifyingPhase Phase = "notifying"
+)
+
+type Phase string
+
+// Progress reports the current phase of an import and its counters. BytesRead and BytesTotal track how much of
+// the leak file was parsed, Records is the number of records parsed so far, and Users and UsersTotal track how
+// many of the parsed users were stored.
+type Progress struct {
+	PhaseStartedAt time.Time
+	Phase          Phase
+	BytesRead      int64
+	BytesTotal     int64
+	Records        int
+	Users          int
+	UsersTotal     int
+}
+
+type ProgressFunc func(p Progress)
+
+// progressTracker reports the progress of an import to a ProgressFunc, if there is one.
+type progressTracker struct {
+	report   ProgressFunc
+	progress Progress
+}
+
+// Fraction returns how much of the current phase is complete, between 0 and 1. It is 0 for phases that cannot
+// be measured.
+func (p Progress) Fraction() float64 {
+	switch {
+	case p.Phase == ParsingPhase && p.BytesTotal > 0:
+		return float64(p.BytesRead) / float64(p.BytesTotal)
+	case p.Phase == StoringPhase && p.UsersTotal > 0:
+		return float64(p.Users) / float64(p.UsersTotal)
+	default:
+		return 0
+	}
+}
+
+// ETA estimates how long it takes to complete the current phase at now, assuming that the rest of the phase is
+// completed at the same pace. It is 0 if it cannot be estimated yet.
+func (p Progress) ETA(now time.Time) time.Duration {
+	f := p.Fraction()
+
+	if f <= 0 || f >= 1 || p.PhaseStartedAt.IsZero() {
+		return 0
+	}
+
+	elapsed := now.Sub(p.PhaseStartedAt)
+
+	return time.Duration(float64(elapsed) * (1 - f) / f)
+}
+
+func newProgressTracker(report ProgressFunc) *progressTracker {
+	return &progressTracker{report: report}
+}
+
+// Starts a new phase and reports it.
+func (t *progressTracker) phase(phase Phase) {
+	t.progress.Phase = phase
+	t.progress.PhaseStartedAt = time.Now()
+	t.notify()
+}
+
+// Reports the progress of parsing the leak file. The parse phase starts with the first report.
+func (t *progressTracker) parsed(p parser.ParseProgress) {
+	if t.progress.Phase != ParsingPhase {
+		t.phase(ParsingPhase)
+	}
+
+	t.progress.BytesRead = p.BytesRead
+	t.progress.BytesTotal = p.BytesTotal
+	t.progress.Records = p.Records
+	t.notify()
+}
+
+// Reports the number of users stored so far.
+func (t *progressTracker) stored(users int) {
+	t.progress.Users = users
+	t.notify()
+}
+
+func (t *progressTracker) notify() {
+	if t.report != nil {
+		t.report(t.progress)
+	}
+}
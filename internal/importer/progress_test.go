@@ -0,0 +1,180 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/importer/importertest"
+	"github.com/palavrapasse/import/internal/parser"
+)
+
+func TestProgressEstimatesRemainingTimeOfPhase(t *testing.T) {
+	now := time.Now()
+
+	p := importer.Progress{
+		PhaseStartedAt: now.Add(-10 * time.Second),
+		Phase:          importer.ParsingPhase,
+		BytesRead:      25,
+		BytesTotal:     100,
+	}
+
+	if eta := p.ETA(now); eta != 30*time.Second {
+		t.Fatalf("A quarter of the leak was parsed in 10 seconds, so the rest should take 30 seconds, but got %v", eta)
+	}
+}
+
+func TestProgressCannotEstimateRemainingTimeOfUnmeasuredPhase(t *testing.T) {
+	p := importer.Progress{
+		PhaseStartedAt: time.Now().Add(-10 * time.Second),
+		Phase:          importer.NotifyingPhase,
+	}
+
+	if eta := p.ETA(time.Now()); eta != 0 {
+		t.Fatalf("Notifying cannot be measured, but an ETA of %v was estimated", eta)
+	}
+}
+
+func TestReadStopsOnceContextIsDone(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "leak.txt")
+
+	if err := os.WriteFile(fp, []byte("a@example.com:password\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	leak := importer.Leak{
+		ShareDate: time.Now(),
+		Path:      fp,
+		Context:   "context",
+		Platforms: []string{"platform"},
+		Leakers:   []string{"leaker"},
+	}
+
+	opts := importer.ParseOptions{
+		Format:         parser.PlainTextFormat,
+		ErrorThreshold: importer.ErrorThreshold{MaxErrors: importer.DefaultMaxErrors, MaxErrorRatio: importer.DefaultMaxErrorRatio},
+	}
+
+	if _, err := importer.Read(ctx, leak, opts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Leak should not be read once the context is done, but got %v", err)
+	}
+}
+
+func TestImportStreamsUsersOfReadLeak(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "leak.txt")
+
+	if err := os.WriteFile(fp, []byte("a@example.com:password\nmalformed\nb@example.com:password\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	leak := importer.Leak{
+		ShareDate: time.Now(),
+		Path:      fp,
+		Context:   "context",
+		Platforms: []string{"platform"},
+		Leakers:   []string{"leaker"},
+	}
+
+	opts := importer.ParseOptions{
+		Format:         parser.PlainTextFormat,
+		ErrorThreshold: importer.ErrorThreshold{MaxErrors: importer.DefaultMaxErrors, MaxErrorRatio: importer.DefaultMaxErrorRatio},
+	}
+
+	lr, err := importer.Read(context.Background(), leak, opts)
+
+	if err != nil {
+		t.Fatalf("Leak should be read, but got %v", err)
+	}
+
+	if len(lr.AffectedUsers) != 0 || lr.Users != 2 || lr.Records != 3 || lr.ErrorCount != 1 {
+		t.Fatalf("Read leak should only count its users, but got %d users (%d counted), %d records and %d errors", len(lr.AffectedUsers), lr.Users, lr.Records, lr.ErrorCount)
+	}
+
+	var stored query.LeakParse
+
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			return 1, opts.Users(func(users query.LeakParse) error {
+				stored = append(stored, users...)
+				return nil
+			})
+		},
+	}
+
+	if _, err := im.Import(context.Background(), lr); err != nil {
+		t.Fatalf("Leak should be imported, but got %v", err)
+	}
+
+	if len(stored) != 2 || stored[0].Email != "a@example.com" || stored[1].Email != "b@example.com" {
+		t.Fatalf("Users of the leak should be streamed to the store, but got %v", stored)
+	}
+}
+
+func TestImportReportsEachPhase(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "leak.txt")
+
+	if err := os.WriteFile(fp, []byte("a@example.com:password\nmalformed\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	var phases []importer.Phase
+
+	onProgress := func(p importer.Progress) {
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+	}
+
+	leak := importer.Leak{
+		ShareDate: time.Now(),
+		Path:      fp,
+		Context:   "context",
+		Platforms: []string{"platform"},
+		Leakers:   []string{"leaker"},
+	}
+
+	opts := importer.ParseOptions{
+		Format:         parser.AutoFormat,
+		ErrorThreshold: importer.ErrorThreshold{MaxErrors: importer.DefaultMaxErrors, MaxErrorRatio: importer.DefaultMaxErrorRatio},
+		OnProgress:     onProgress,
+	}
+
+	lr, err := importer.Read(context.Background(), leak, opts)
+
+	if err != nil {
+		t.Fatalf("Leak should be read, but got %v", err)
+	}
+
+	im := importer.Importer{
+		Store: func(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+			return 1, nil
+		},
+		Notifiers:  []importer.Notifier{importertest.Notifier{OnNotify: func(n importer.Notification) error { return nil }}},
+		OnProgress: onProgress,
+	}
+
+	if _, err := im.Import(context.Background(), lr); err != nil {
+		t.Fatalf("Leak should be imported, but got %v", err)
+	}
+
+	expected := []importer.Phase{importer.ReadingPhase, importer.ParsingPhase, importer.StoringPhase, importer.NotifyingPhase}
+
+	if len(phases) != len(expected) {
+		t.Fatalf("Import should report phases %v, but got %v", expected, phases)
+	}
+
+	for i := range expected {
+		if phases[i] != expected[i] {
+			t.Fatalf("Import should report phases %v, but got %v", expected, phases)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"fmt"
+)
+
+// Default values of the error threshold, which do not limit the number of errors.
+const (
+	DefaultMaxErrors     = -1
+	DefaultMaxErrorRatio = 1.0
+)
+
+// ErrorThreshold limits how many records of a leak can fail to parse before the import is aborted. A negative
+// MaxErrors does not limit the number of errors.
+type ErrorThreshold struct {
+	MaxErrors     int
+	MaxErrorRatio float64
+}
+
+// ThresholdError is returned when the records of a leak that could not be parsed exceed the error threshold.
+type ThresholdError struct {
+	Threshold ErrorThreshold
+	Errors    int
+	Records   int
+}
+
+func (t ErrorThreshold) Validate() error {
+	if t.MaxErrorRatio < 0 || t.MaxErrorRatio > 1 {
+		return fmt.Errorf("max error ratio should be between 0 and 1 (%v)", t.MaxErrorRatio)
+	}
+
+	return nil
+}
+
+// Check returns a ThresholdError if the number of errors exceeds the threshold. The ratio of errors is given by
+// the number of errors over the number of records (errors and valid users).
+func (t ErrorThreshold) Check(errorsCount int, usersCount int) error {
+	err := &ThresholdError{
+		Threshold: t,
+		Errors:    errorsCount,
+		Records:   errorsCount + usersCount,
+	}
+
+	if t.MaxErrors >= 0 && errorsCount > t.MaxErrors {
+		return err
+	}
+
+	if err.Records != 0 && err.Ratio() > t.MaxErrorRatio {
+		return err
+	}
+
+	return nil
+}
+
+func (e *ThresholdError) Ratio() float64 {
+	if e.Records == 0 {
+		return 0
+	}
+
+	return float64(e.Errors) / float64(e.Records)
+}
+
+func (e *ThresholdError) Error() string {
+	if e.Threshold.MaxErrors >= 0 && e.Errors > e.Threshold.MaxErrors {
+		return fmt.Sprintf("Aborted import: %d records could not be parsed, which exceeds max errors (%d)", e.Errors, e.Threshold.MaxErrors)
+	}
+
+	return fmt.Sprintf("Aborted import: %.2f%% of records could not be parsed (%d of %d), which exceeds max error ratio (%v)", e.Ratio()*100, e.Errors, e.Records, e.Threshold.MaxErrorRatio)
+}
@@ -0,0 +1,38 @@
+package importer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorThresholdAbortsWhenMaxErrorsIsExceeded(t *testing.T) {
+	err := ErrorThreshold{MaxErrors: 2, MaxErrorRatio: DefaultMaxErrorRatio}.Check(3, 1000)
+
+	var terr *ThresholdError
+
+	if !errors.As(err, &terr) {
+		t.Fatalf("3 errors exceed max errors (2), but got %v", err)
+	}
+}
+
+func TestErrorThresholdAbortsWhenMaxErrorRatioIsExceeded(t *testing.T) {
+	err := ErrorThreshold{MaxErrors: DefaultMaxErrors, MaxErrorRatio: 0.1}.Check(2, 8)
+
+	if err == nil {
+		t.Fatalf("20%% of errors exceed max error ratio (10%%), but no error was identified")
+	}
+}
+
+func TestDefaultErrorThresholdDoesNotAbort(t *testing.T) {
+	err := ErrorThreshold{MaxErrors: DefaultMaxErrors, MaxErrorRatio: DefaultMaxErrorRatio}.Check(10, 0)
+
+	if err != nil {
+		t.Fatalf("Default error threshold should not limit errors, but got %v", err)
+	}
+}
+
+func TestCannotValidateErrorThresholdWithInvalidRatio(t *testing.T) {
+	if err := (ErrorThreshold{MaxErrorRatio: 1.5}).Validate(); err == nil {
+		t.Fatalf("Max error ratio should be between 0 and 1, but 1.5 was accepted")
+	}
+}
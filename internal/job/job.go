@@ -0,0 +1,89 @@
+package job
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Succeeded State = "succeeded"
+	Failed    State = "failed"
+)
+
+const uploadDirPattern = "import-upload-"
+
+type State string
+
+// Job is an import of an uploaded leak that runs in the background. Records and Users report its progress: the
+// number of records that were parsed and the number of users that were stored. While the job is running, Progress
+// reports its current phase as well.
+type Job struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Progress  importer.Progress
+	Leak      importer.Leak
+	FileName  string
+	State     State
+	Format    string
+	Error     string
+	Id        int64
+	LeakId    int64
+	Records   int
+	Users     int
+	Errors    int
+}
+
+// Finished returns whether the job either succeeded or failed.
+func (j Job) Finished() bool {
+	return j.State == Succeeded || j.State == Failed
+}
+
+// SaveUpload saves an uploaded leak in a new temporary directory, keeping its base name so that its format can be
+// detected by extension.
+func SaveUpload(r io.Reader, fileName string) (string, error) {
+	dir, err := os.MkdirTemp("", uploadDirPattern)
+
+	if err != nil {
+		return "", err
+	}
+
+	name := filepath.Base(fileName)
+
+	if name == "." || name == string(filepath.Separator) {
+		name = "leak"
+	}
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+
+	if err != nil {
+		return path, err
+	}
+
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return path, err
+	}
+
+	return path, f.Close()
+}
+
+// RemoveUpload removes an uploaded leak saved by SaveUpload, along with its directory. Paths that were not saved
+// by SaveUpload are left untouched.
+func RemoveUpload(path string) error {
+	dir := filepath.Dir(path)
+
+	if !strings.HasPrefix(filepath.Base(dir), uploadDirPattern) {
+		return nil
+	}
+
+	return os.RemoveAll(dir)
+}
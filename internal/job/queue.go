@@ -0,0 +1,309 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+)
+
+const DefaultWorkers = 2
+
+// Minimum time between two updates of the progress of a running job in the store. Subscribers are notified of
+// every update.
+const ProgressSaveInterval = time.Second
+
+// Queue runs import jobs in the order they were submitted, with at most Workers jobs running at the same time.
+// The latest state of running jobs is kept in memory, and handed out to their subscribers.
+type Queue struct {
+	store       *Store
+	importer    importer.Importer
+	options     importer.ParseOptions
+	cond        *sync.Cond
+	pending     []Job
+	workers     int
+	running     sync.WaitGroup
+	mutex       sync.Mutex
+	live        map[int64]Job
+	subscribers map[int64]map[chan Job]struct{}
+}
+
+func NewQueue(store *Store, im importer.Importer, opts importer.ParseOptions, workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Queue{
+		store:       store,
+		importer:    im,
+		options:     opts,
+		cond:        sync.NewCond(&sync.Mutex{}),
+		workers:     workers,
+		live:        map[int64]Job{},
+		subscribers: map[int64]map[chan Job]struct{}{},
+	}
+}
+
+// Start recovers the jobs that were queued when the service stopped and starts the workers, which run jobs until
+// ctx is done. Running jobs are then interrupted, and queued jobs are left for the next start.
+func (q *Queue) Start(ctx context.Context) error {
+	jobs, err := q.store.Recover()
+
+	if err != nil {
+		return err
+	}
+
+	if len(jobs) != 0 {
+		logging.Aspirador.Info(fmt.Sprintf("Resuming %d queued import jobs", len(jobs)))
+	}
+
+	q.cond.L.Lock()
+	q.pending = append(q.pending, jobs...)
+	q.cond.L.Unlock()
+
+	q.running.Add(q.workers)
+
+	for i := 0; i < q.workers; i++ {
+		go q.work(ctx)
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		q.cond.L.Lock()
+		q.cond.Broadcast()
+		q.cond.L.Unlock()
+	}()
+
+	return nil
+}
+
+// Wait waits for the workers to stop, after the context of Start is done.
+func (q *Queue) Wait() {
+	q.running.Wait()
+}
+
+// Submit stores a new job of the leak and queues it.
+func (q *Queue) Submit(leak importer.Leak, fileName string) (Job, error) {
+	j := Job{
+		Leak:     leak,
+		FileName: fileName,
+		State:    Queued,
+	}
+
+	if err := q.store.Create(&j); err != nil {
+		return j, err
+	}
+
+	q.cond.L.Lock()
+	q.pending = append(q.pending, j)
+	q.cond.L.Unlock()
+	q.cond.Signal()
+
+	return j, nil
+}
+
+// Get returns the job with the given id, or ErrJobNotFound. Running jobs are returned with their latest progress.
+func (q *Queue) Get(id int64) (Job, error) {
+	q.mutex.Lock()
+	j, ok := q.live[id]
+	q.mutex.Unlock()
+
+	if ok {
+		return j, nil
+	}
+
+	return q.store.Get(id)
+}
+
+// Subscribe returns a channel that receives the current state of the job with the given id, followed by each of
+// its updates. Since only the latest update is kept, a slow receiver skips the updates it missed. The channel is
+// closed after the job finishes, and unsubscribe stops receiving updates before that.
+func (q *Queue) Subscribe(id int64) (updates <-chan Job, unsubscribe func(), err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	j, ok := q.live[id]
+
+	if !ok {
+		j, err = q.store.Get(id)
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ch := make(chan Job, 1)
+	ch <- j
+
+	if j.Finished() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	if q.subscribers[id] == nil {
+		q.subscribers[id] = map[chan Job]struct{}{}
+	}
+
+	q.subscribers[id][ch] = struct{}{}
+
+	unsubscribe = func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+
+		delete(q.subscribers[id], ch)
+	}
+
+	return ch, unsubscribe, nil
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.running.Done()
+
+	for {
+		q.cond.L.Lock()
+
+		for len(q.pending) == 0 && ctx.Err() == nil {
+			q.cond.Wait()
+		}
+
+		if ctx.Err() != nil {
+			q.cond.L.Unlock()
+			return
+		}
+
+		j := q.pending[0]
+		q.pending = q.pending[1:]
+
+		q.cond.L.Unlock()
+
+		q.run(ctx, &j)
+	}
+}
+
+func (q *Queue) run(ctx context.Context, j *Job) {
+	defer func() {
+		if err := RemoveUpload(j.Leak.Path); err != nil {
+			logging.Aspirador.Warning(fmt.Sprintf("Could not remove upload of job %d: %v", j.Id, err))
+		}
+	}()
+
+	logging.Aspirador.Info(fmt.Sprintf("Starting import job %d (%s)", j.Id, j.FileName))
+
+	var saved time.Time
+
+	onProgress := func(p importer.Progress) {
+		j.Progress = p
+
+		if p.Records > j.Records {
+			j.Records = p.Records
+		}
+
+		j.Users = p.Users
+
+		if time.Since(saved) < ProgressSaveInterval {
+			q.publish(*j)
+			return
+		}
+
+		saved = time.Now()
+		q.update(j)
+	}
+
+	opts := q.options
+	opts.OnProgress = onProgress
+
+	im := q.importer
+	im.OnProgress = onProgress
+
+	j.State = Running
+	q.update(j)
+
+	lr, err := importer.Read(ctx, j.Leak, opts)
+
+	if err != nil {
+		q.fail(j, err)
+		return
+	}
+
+	j.Format = lr.Format
+	j.Errors = lr.ErrorCount
+	j.Records = lr.Records
+	q.update(j)
+
+	leakId, err := im.Import(ctx, lr)
+
+	if leakId != 0 {
+		j.LeakId = int64(leakId)
+		j.Users = lr.Users
+	}
+
+	if err != nil {
+		q.fail(j, err)
+		return
+	}
+
+	j.State = Succeeded
+	q.update(j)
+
+	logging.Aspirador.Info(fmt.Sprintf("Import job %d succeeded (leak %d)", j.Id, j.LeakId))
+}
+
+// Marks the job as failed. Jobs of leaks that were already imported point to the leak id of the existing leak.
+func (q *Queue) fail(j *Job, err error) {
+	logging.Aspirador.Error(fmt.Sprintf("Import job %d failed: %v", j.Id, err))
+
+	var derr *importer.DuplicateImportError
+
+	if errors.As(err, &derr) {
+		j.LeakId = int64(derr.LeakId)
+	}
+
+	j.State = Failed
+	j.Error = err.Error()
+	q.update(j)
+}
+
+// Stores the job and publishes it to its subscribers.
+func (q *Queue) update(j *Job) {
+	if err := q.store.Update(j); err != nil {
+		logging.Aspirador.Error(err.Error())
+	}
+
+	q.publish(*j)
+}
+
+// Keeps the latest state of a running job and sends it to its subscribers, replacing any update they did not
+// receive yet. Subscribers of finished jobs are closed, since there are no more updates.
+func (q *Queue) publish(j Job) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if j.Finished() {
+		j.Progress = importer.Progress{}
+		delete(q.live, j.Id)
+	} else {
+		q.live[j.Id] = j
+	}
+
+	for ch := range q.subscribers[j.Id] {
+		select {
+		case <-ch:
+		default:
+		}
+
+		ch <- j
+
+		if j.Finished() {
+			close(ch)
+		}
+	}
+
+	if j.Finished() {
+		delete(q.subscribers, j.Id)
+	}
+}
@@ -0,0 +1,181 @@
+package job
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	// Registers the sqlite3 driver, which is also used to store leaks.
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+const sqliteDriverName = "sqlite3"
+
+const listSeparator = ","
+
+const createJobTableQuery = `CREATE TABLE IF NOT EXISTS ImportJob (
+	jobid INTEGER PRIMARY KEY AUTOINCREMENT,
+	state TEXT NOT NULL,
+	filename TEXT NOT NULL,
+	leakpath TEXT NOT NULL,
+	context TEXT NOT NULL,
+	platforms TEXT NOT NULL,
+	leakers TEXT NOT NULL,
+	sharedatesc INTEGER NOT NULL,
+	format TEXT NOT NULL,
+	records INTEGER NOT NULL,
+	users INTEGER NOT NULL,
+	errors INTEGER NOT NULL,
+	leakid INTEGER NOT NULL,
+	error TEXT NOT NULL,
+	createdatms INTEGER NOT NULL,
+	updatedatms INTEGER NOT NULL
+)`
+
+const insertJobQuery = `INSERT INTO ImportJob (state, filename, leakpath, context, platforms, leakers, sharedatesc,
+	format, records, users, errors, leakid, error, createdatms, updatedatms)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const updateJobQuery = `UPDATE ImportJob SET state = ?, format = ?, records = ?, users = ?, errors = ?, leakid = ?,
+	error = ?, updatedatms = ? WHERE jobid = ?`
+
+const selectJobQuery = `SELECT jobid, state, filename, leakpath, context, platforms, leakers, sharedatesc,
+	format, records, users, errors, leakid, error, createdatms, updatedatms FROM ImportJob`
+
+// Message of the jobs that were running when the service stopped.
+const interruptedJobError = "import was interrupted by a restart of the service"
+
+var ErrJobNotFound = errors.New("job not found")
+
+// Store keeps the history of import jobs in a SQLite database, so that it outlives the service.
+type Store struct {
+	db *sql.DB
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// OpenStore opens (or creates) the SQLite database of jobs in path.
+func OpenStore(path string) (*Store, error) {
+	db, err := sql.Open(sqliteDriverName, path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open jobs database %s: %w", path, err)
+	}
+
+	if _, err := db.Exec(createJobTableQuery); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create jobs table in %s: %w", path, err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create stores a new job, setting its id and creation time.
+func (s *Store) Create(j *Job) error {
+	j.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	j.UpdatedAt = j.CreatedAt
+
+	res, err := s.db.Exec(insertJobQuery,
+		j.State, j.FileName, j.Leak.Path, j.Leak.Context,
+		strings.Join(j.Leak.Platforms, listSeparator), strings.Join(j.Leak.Leakers, listSeparator), j.Leak.ShareDate.Unix(),
+		j.Format, j.Records, j.Users, j.Errors, j.LeakId, j.Error, j.CreatedAt.UnixMilli(), j.UpdatedAt.UnixMilli(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not store job: %w", err)
+	}
+
+	j.Id, err = res.LastInsertId()
+
+	return err
+}
+
+// Update stores the state and progress of a job, setting its update time.
+func (s *Store) Update(j *Job) error {
+	j.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	_, err := s.db.Exec(updateJobQuery,
+		j.State, j.Format, j.Records, j.Users, j.Errors, j.LeakId, j.Error, j.UpdatedAt.UnixMilli(), j.Id,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not update job %d: %w", j.Id, err)
+	}
+
+	return nil
+}
+
+// Get returns the job with the given id, or ErrJobNotFound.
+func (s *Store) Get(id int64) (Job, error) {
+	j, err := scanJob(s.db.QueryRow(selectJobQuery+" WHERE jobid = ?", id))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return j, ErrJobNotFound
+	}
+
+	return j, err
+}
+
+// Recover marks the jobs that were running when the service stopped as failed, and returns the jobs that are
+// still queued, oldest first.
+func (s *Store) Recover() ([]Job, error) {
+	_, err := s.db.Exec("UPDATE ImportJob SET state = ?, error = ?, updatedatms = ? WHERE state = ?",
+		Failed, interruptedJobError, time.Now().UTC().UnixMilli(), Running,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not recover interrupted jobs: %w", err)
+	}
+
+	rows, err := s.db.Query(selectJobQuery+" WHERE state = ? ORDER BY jobid", Queued)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not recover queued jobs: %w", err)
+	}
+
+	defer rows.Close()
+
+	var jobs []Job
+
+	for rows.Next() {
+		j, err := scanJob(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+func scanJob(row scanner) (Job, error) {
+	var j Job
+	var platforms, leakers string
+	var shareDateSC, createdAtMS, updatedAtMS int64
+
+	err := row.Scan(&j.Id, &j.State, &j.FileName, &j.Leak.Path, &j.Leak.Context, &platforms, &leakers, &shareDateSC,
+		&j.Format, &j.Records, &j.Users, &j.Errors, &j.LeakId, &j.Error, &createdAtMS, &updatedAtMS)
+
+	if err != nil {
+		return j, err
+	}
+
+	j.Leak.Platforms = importer.SplitList(platforms)
+	j.Leak.Leakers = importer.SplitList(leakers)
+	j.Leak.ShareDate = time.Unix(shareDateSC, 0).UTC()
+	j.CreatedAt = time.UnixMilli(createdAtMS).UTC()
+	j.UpdatedAt = time.UnixMilli(updatedAtMS).UTC()
+
+	return j, nil
+}
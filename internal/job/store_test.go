@@ -0,0 +1,106 @@
+package job
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+func TestStoreGetsCreatedJob(t *testing.T) {
+	s := openTestStore(t)
+
+	j := Job{
+		Leak: importer.Leak{
+			ShareDate: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+			Path:      "leak.txt",
+			Context:   "context",
+			Platforms: []string{"platform1", "platform2"},
+			Leakers:   []string{"leaker"},
+		},
+		FileName: "leak.txt",
+		State:    Queued,
+	}
+
+	if err := s.Create(&j); err != nil || j.Id == 0 {
+		t.Fatalf("Job should be created with an id, but got %d (%v)", j.Id, err)
+	}
+
+	j.State = Succeeded
+	j.LeakId = 7
+	j.Users = 10
+
+	if err := s.Update(&j); err != nil {
+		t.Fatalf("Job should be updated, but got %v", err)
+	}
+
+	got, err := s.Get(j.Id)
+
+	if err != nil {
+		t.Fatalf("Job %d should be found, but got %v", j.Id, err)
+	}
+
+	if got.State != Succeeded || got.LeakId != 7 || got.Users != 10 || len(got.Leak.Platforms) != 2 || !got.Leak.ShareDate.Equal(j.Leak.ShareDate) {
+		t.Fatalf("Job should be stored as it was updated, but got %v", got)
+	}
+}
+
+func TestStoreCannotGetUnknownJob(t *testing.T) {
+	_, err := openTestStore(t).Get(42)
+
+	if !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("Job 42 was never created, but got %v", err)
+	}
+}
+
+func TestStoreRecoversQueuedJobsAndFailsRunningJobs(t *testing.T) {
+	s := openTestStore(t)
+
+	running := Job{State: Running}
+	queued := Job{State: Queued}
+
+	for _, j := range []*Job{&running, &queued} {
+		if err := s.Create(j); err != nil {
+			panic(err)
+		}
+	}
+
+	jobs, err := s.Recover()
+
+	if err != nil || len(jobs) != 1 || jobs[0].Id != queued.Id {
+		t.Fatalf("Only the queued job should be recovered, but got %v (%v)", jobs, err)
+	}
+
+	interrupted, err := s.Get(running.Id)
+
+	if err != nil || interrupted.State != Failed || len(interrupted.Error) == 0 {
+		t.Fatalf("Running job should fail after being interrupted, but got %v (%v)", interrupted, err)
+	}
+}
+
+func TestRemoveUploadKeepsFilesThatWereNotUploaded(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := RemoveUpload(filepath.Join(dir, "leak.txt")); err != nil {
+		t.Fatalf("Removing a file that was not uploaded should be ignored, but got %v", err)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("Directory %s should not be removed, but got %v", dir, err)
+	}
+}
+
+func openTestStore(t *testing.T) *Store {
+	s, err := OpenStore(filepath.Join(t.TempDir(), "jobs.sqlite"))
+
+	if err != nil {
+		panic(err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
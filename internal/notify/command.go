@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+)
+
+// Maximum number of bytes of the standard error of a command that are reported when it fails.
+const maxReportedStderrBytes = 512
+
+// CommandTimeout is how long a command is given to handle a notification before it is killed.
+var CommandTimeout = time.Minute
+
+// Command notifies a local command of stored leaks, by running it with the JSON payload of each notification on its
+// standard input. A notification fails if the command exits with a non-zero status.
+type Command struct {
+	Path string
+}
+
+func (c Command) Notify(ctx context.Context, n importer.Notification) error {
+	leakId := n.LeakId
+
+	logging.Aspirador.Info(fmt.Sprintf("Running %s to notify new leak %d", c.Path, leakId))
+
+	body, err := n.Payload()
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, c.Path)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return fmt.Errorf("command %s was stopped while notifying new leak %d: %w", c.Path, leakId, cerr)
+		}
+
+		return fmt.Errorf("command %s failed to notify new leak %d: %w (%s)", c.Path, leakId, err, lastBytes(stderr.String(), maxReportedStderrBytes))
+	}
+
+	logging.Aspirador.Info(fmt.Sprintf("Successful notification of new leak %d", leakId))
+
+	return nil
+}
+
+func (c Command) Target() string {
+	return CommandTargetPrefix + c.Path
+}
+
+// Returns the last n bytes of s, which are the most likely to explain why a command failed.
+func lastBytes(s string, n int) string {
+	s = strings.TrimSpace(s)
+
+	if len(s) > n {
+		return s[len(s)-n:]
+	}
+
+	return s
+}
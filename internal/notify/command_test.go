@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palavrapasse/import/internal/importer/importertest"
+)
+
+func TestCommandReadsPayloadFromStandardInput(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "payload.json")
+	script := writeScript(t, dir, fmt.Sprintf("cat > %s\n", out))
+
+	if err := (Command{Path: script}).Notify(context.Background(), importertest.NewNotification(7)); err != nil {
+		t.Fatalf("Command should be notified of leak 7, but got %v", err)
+	}
+
+	b, err := os.ReadFile(out)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if string(b) != importertest.Payload(7) {
+		t.Fatalf("Command should read the payload of leak 7 from its standard input, but got %s", b)
+	}
+}
+
+func TestCommandFailsWithNonZeroExitStatus(t *testing.T) {
+	script := writeScript(t, t.TempDir(), "echo 'subscribers are unavailable' >&2\nexit 3\n")
+
+	err := (Command{Path: script}).Notify(context.Background(), importertest.NewNotification(7))
+
+	if err == nil || !strings.Contains(err.Error(), "subscribers are unavailable") {
+		t.Fatalf("Command that exits with status 3 should fail with its standard error, but got %v", err)
+	}
+}
+
+func writeScript(t *testing.T, dir string, body string) string {
+	fp := filepath.Join(dir, "on-leak.sh")
+
+	if err := os.WriteFile(fp, []byte("#!/bin/sh\n"+body), 0700); err != nil {
+		panic(err)
+	}
+
+	return fp
+}
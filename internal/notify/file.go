@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+)
+
+// File notifies a file of stored leaks, by appending the JSON payload of each notification to it as a line. The
+// file is created if it does not exist. If it is a named pipe (FIFO), a reader must have it open, otherwise the
+// notification fails.
+type File struct {
+	Path string
+}
+
+func (f File) Notify(ctx context.Context, n importer.Notification) error {
+	leakId := n.LeakId
+
+	logging.Aspirador.Info(fmt.Sprintf("Writing notification of new leak %d to %s", leakId, f.Path))
+
+	body, err := n.Payload()
+
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("notification was interrupted: %w", err)
+	}
+
+	flag := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+
+	// Opening a named pipe blocks until it has a reader, so it fails right away instead.
+	if info, err := os.Stat(f.Path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		flag = os.O_WRONLY | syscall.O_NONBLOCK
+	}
+
+	file, err := os.OpenFile(f.Path, flag, 0o644)
+
+	if err != nil {
+		return fmt.Errorf("could not open %s to notify new leak %d: %w", f.Path, leakId, err)
+	}
+
+	// A single write of a line keeps notifications whole, even if several importers share the same file.
+	if _, err := file.Write(append(body, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("could not write notification of new leak %d to %s: %w", leakId, f.Path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("could not write notification of new leak %d to %s: %w", leakId, f.Path, err)
+	}
+
+	logging.Aspirador.Info(fmt.Sprintf("Successful notification of new leak %d", leakId))
+
+	return nil
+}
+
+func (f File) Target() string {
+	return FileTargetPrefix + f.Path
+}
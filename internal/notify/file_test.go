@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/import/internal/importer/importertest"
+)
+
+func TestFileAppendsPayloadLines(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "leaks.ndjson")
+	f := File{Path: fp}
+
+	for _, leakId := range []int64{7, 8} {
+		if err := f.Notify(context.Background(), importertest.NewNotification(entity.AutoGenKey(leakId))); err != nil {
+			t.Fatalf("File should be notified of leak %d, but got %v", leakId, err)
+		}
+	}
+
+	b, err := os.ReadFile(fp)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if string(b) != importertest.Payload(7)+"\n"+importertest.Payload(8)+"\n" {
+		t.Fatalf("File should contain a line for each notification, but got %s", b)
+	}
+}
+
+func TestFileIsNotNotifiedOnceContextIsDone(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "leaks.ndjson")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := (File{Path: fp}).Notify(ctx, importertest.NewNotification(7)); err == nil {
+		t.Fatalf("File should not be notified once the context is done")
+	}
+
+	if _, err := os.Stat(fp); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("File should not be written once the context is done")
+	}
+}
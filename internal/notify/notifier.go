@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/palavrapasse/import/internal/http"
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+const (
+	CommandTargetPrefix = "exec:"
+	FileTargetPrefix    = "file:"
+)
+
+// NewNotifier returns the Notifier of target, which is either an http(s) URL of a service that is notified with a
+// webhook, exec:PATH of a command that is run for each notification, or file:PATH of a file or named pipe (FIFO)
+// that notifications are written to. Webhooks sign their notifications with opts.Secret, unless it is empty.
+func NewNotifier(target string, opts importer.NotifierOptions) (importer.Notifier, error) {
+	switch {
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return http.Webhook{URL: target, Secret: []byte(opts.Secret)}, nil
+	case strings.HasPrefix(target, CommandTargetPrefix) && len(target) > len(CommandTargetPrefix):
+		return Command{Path: strings.TrimPrefix(target, CommandTargetPrefix)}, nil
+	case strings.HasPrefix(target, FileTargetPrefix) && len(target) > len(FileTargetPrefix):
+		return File{Path: strings.TrimPrefix(target, FileTargetPrefix)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification target %q (expected an http(s) URL, %sPATH or %sPATH)", target, CommandTargetPrefix, FileTargetPrefix)
+	}
+}
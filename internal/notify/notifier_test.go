@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/palavrapasse/import/internal/http"
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+func TestNewNotifierOfURLIsWebhook(t *testing.T) {
+	n, err := NewNotifier("https://subscribeService/notify", importer.NotifierOptions{Secret: "shared secret"})
+
+	if w, ok := n.(http.Webhook); err != nil || !ok || w.URL != "https://subscribeService/notify" || string(w.Secret) != "shared secret" {
+		t.Fatalf("Notifier of an https URL should be a webhook that signs with the secret, but got %v (%v)", n, err)
+	}
+}
+
+func TestNewNotifierOfExecTargetIsCommand(t *testing.T) {
+	n, err := NewNotifier("exec:/usr/local/bin/on-leak", importer.NotifierOptions{})
+
+	if c, ok := n.(Command); err != nil || !ok || c.Path != "/usr/local/bin/on-leak" {
+		t.Fatalf("Notifier of an exec target should be a command, but got %v (%v)", n, err)
+	}
+
+	if n.Target() != "exec:/usr/local/bin/on-leak" {
+		t.Fatalf("Target of a command should be the target it was created for, but got %s", n.Target())
+	}
+}
+
+func TestNewNotifierOfFileTargetIsFile(t *testing.T) {
+	n, err := NewNotifier("file:/var/run/leaks.fifo", importer.NotifierOptions{})
+
+	if f, ok := n.(File); err != nil || !ok || f.Path != "/var/run/leaks.fifo" {
+		t.Fatalf("Notifier of a file target should be a file, but got %v (%v)", n, err)
+	}
+
+	if n.Target() != "file:/var/run/leaks.fifo" {
+		t.Fatalf("Target of a file should be the target it was created for, but got %s", n.Target())
+	}
+}
+
+func TestCannotCreateNotifierOfUnsupportedTarget(t *testing.T) {
+	for _, target := range []string{"", "subscribeService/notify", "ftp://subscribeService", "exec:", "file:"} {
+		if _, err := NewNotifier(target, importer.NotifierOptions{}); err == nil {
+			t.Fatalf("Target %q is not supported, but no error was identified", target)
+		}
+	}
+}
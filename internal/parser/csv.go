@@ -0,0 +1,396 @@
+package parser
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/palavrapasse/damn/pkg/entity/query"
+)
+
+const DefaultCSVComma = ','
+
+const CSVFormat = "csv"
+
+const (
+	csvHeaderConfidence     = 0.9
+	csvRecordsConfidence    = 0.75
+	csvNoEmailConfidence    = 0.5
+	csvTwoColumnsConfidence = 0.3
+)
+
+// CSVLeakParser parses leaks stored as RFC 4180 CSV files. Email and password columns can be mapped by
+// header name or zero based index. If left empty, columns are inferred from the header (if any) or from the
+// first record that contains an email, and the records before it are reported as parse errors.
+type CSVLeakParser struct {
+	FilePath       string
+	Include        string
+	EmailColumn    string
+	PasswordColumn string
+	Comma          rune
+	Input          io.Writer
+	OnProgress     OnParseProgressCallback
+}
+
+type csvRecord struct {
+	fields []string
+	err    error
+	text   string
+	pos    recordPosition
+	comma  rune
+}
+
+// csvInput is the input of a csv.Reader, which keeps the bytes that the reader read ahead of its last record, so that
+// the text of malformed records, which the reader does not return, can be recovered from their offsets.
+type csvInput struct {
+	r      io.Reader
+	buf    []byte
+	offset int64
+}
+
+type csvColumns struct {
+	email    int
+	password int
+}
+
+func init() {
+	RegisterLeakParser(LeakParserRegistration{
+		Name:       CSVFormat,
+		Extensions: []string{".csv"},
+		Sniff:      sniffCSV,
+		Create: func(opts LeakParserOptions) LeakParser {
+			var comma rune
+
+			if utf8.RuneCountInString(opts.Separator) == 1 {
+				comma, _ = utf8.DecodeRuneInString(opts.Separator)
+			}
+
+			return CSVLeakParser{
+				FilePath:       opts.FilePath,
+				Include:        opts.Include,
+				EmailColumn:    opts.EmailColumn,
+				PasswordColumn: opts.PasswordColumn,
+				Comma:          comma,
+				Input:          opts.Input,
+				OnProgress:     opts.OnProgress,
+			}
+		},
+	})
+}
+
+func (p CSVLeakParser) Parse(ctx context.Context, ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
+	return parseLeakFile(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, ecb...)
+}
+
+func (p CSVLeakParser) ParseBatches(ctx context.Context, bcb OnLeakParseBatchCallback, ecb ...OnParseErrorCallback) error {
+	return parseLeakFileBatches(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, bcb, ecb...)
+}
+
+func (p CSVLeakParser) ParseStream(r io.Reader, ecb ...OnParseErrorCallback) <-chan LeakParseBatch {
+	produce, convert, err := p.pipeline(r)
+
+	if err != nil {
+		return errorLeakParseStream(err, ecb...)
+	}
+
+	return streamLeakParse(produce, convert, ecb...)
+}
+
+func (p CSVLeakParser) Preview(r io.Reader, limit int) ([]ParsedRecord, error) {
+	return previewLeakParse(r, p.pipeline, limit)
+}
+
+func (p CSVLeakParser) pipeline(r io.Reader) (func(emit func(csvRecord)) error, func(csvRecord) (Credentials, error), error) {
+	input := &csvInput{r: r}
+	reader := p.newReader(input)
+
+	first, err := reader.Read()
+	firstPos := recordPosition{line: 1}
+
+	input.discard(reader.InputOffset())
+
+	if errors.Is(err, io.EOF) {
+		err = fmt.Errorf("can't process empty leak")
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := isCSVHeader(first, p.EmailColumn, p.PasswordColumn)
+	sample := first
+
+	var leading []csvRecord
+
+	if !header {
+		leading = append(leading, csvRecord{fields: first, pos: firstPos, comma: reader.Comma})
+	}
+
+	// Without a header, columns are inferred from the first record that contains an email, so the records before
+	// it are read ahead, up to a chunk of them.
+	for !header && len(strings.TrimSpace(p.EmailColumn)) == 0 && findCSVEmailColumn(sample) < 0 && len(leading) < MaxLinesOfGoroutine {
+		record, err := readCSVRecord(reader, input)
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		leading = append(leading, record)
+
+		if record.err == nil {
+			sample = record.fields
+		}
+	}
+
+	columns, err := p.findColumns(sample, header)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	produce := func(emit func(csvRecord)) error {
+		for _, record := range leading {
+			emit(record)
+		}
+
+		for {
+			record, err := readCSVRecord(reader, input)
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			emit(record)
+		}
+	}
+
+	convert := func(record csvRecord) (Credentials, error) {
+		return csvRecordToCredentials(record, columns)
+	}
+
+	return produce, convert, nil
+}
+
+func (p CSVLeakParser) newReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comma = p.Comma
+
+	if reader.Comma == 0 {
+		reader.Comma = DefaultCSVComma
+	}
+
+	return reader
+}
+
+// Finds the columns of the leak in its header, or in a record that contains an email if it has no header.
+func (p CSVLeakParser) findColumns(record []string, header bool) (csvColumns, error) {
+	email, err := findColumn(p.EmailColumn, record, header, emailColumnNames)
+
+	if err != nil {
+		return csvColumns{}, err
+	}
+
+	if email < 0 && !header {
+		email = findCSVEmailColumn(record)
+	}
+
+	if email < 0 {
+		return csvColumns{}, fmt.Errorf("input incorrect. Could not find email column in %v", record)
+	}
+
+	password, err := findColumn(p.PasswordColumn, record, header, passwordColumnNames)
+
+	if err != nil {
+		return csvColumns{}, err
+	}
+
+	if password < 0 && !header {
+		password = email + 1
+	}
+
+	if password < 0 {
+		return csvColumns{}, fmt.Errorf("input incorrect. Could not find password column in %v", record)
+	}
+
+	if email == password {
+		return csvColumns{}, fmt.Errorf("input incorrect. Email and password can not be mapped to the same column (%d)", email)
+	}
+
+	return csvColumns{
+		email:    email,
+		password: password,
+	}, nil
+}
+
+// CSV is detected when every sampled record has the same number of fields. Records with only two fields are
+// likely to be plain text, so those get a lower confidence.
+func sniffCSV(sample []byte) float64 {
+	lines := sampleLines(sample)
+	reader := (CSVLeakParser{}).newReader(strings.NewReader(strings.Join(lines, "\n")))
+
+	records, err := reader.ReadAll()
+
+	if err != nil || len(records) == 0 {
+		return 0
+	}
+
+	nfields := len(records[0])
+
+	if nfields < NumberPositions {
+		return 0
+	}
+
+	for _, r := range records {
+		if len(r) != nfields {
+			return 0
+		}
+	}
+
+	first := records[0]
+
+	switch {
+	case isCSVHeader(first) && indexOfAnyColumn(first, emailColumnNames) >= 0:
+		return csvHeaderConfidence
+	case nfields == NumberPositions:
+		return csvTwoColumnsConfidence
+	case findCSVEmailColumn(records[len(records)-1]) >= 0:
+		return csvRecordsConfidence
+	default:
+		return csvNoEmailConfidence
+	}
+}
+
+func findCSVEmailColumn(record []string) int {
+	for i, f := range record {
+		if _, err := query.NewEmail(f); err == nil {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Reads the next record of the leak from input, which carries its error and its text if it is malformed. Returns
+// io.EOF at the end of the leak.
+func readCSVRecord(reader *csv.Reader, input *csvInput) (csvRecord, error) {
+	pos := recordPosition{offset: reader.InputOffset()}
+	fields, err := reader.Read()
+
+	defer input.discard(reader.InputOffset())
+
+	var perr *csv.ParseError
+
+	if errors.As(err, &perr) {
+		pos.line = perr.StartLine
+		text := strings.TrimRight(input.text(pos.offset, reader.InputOffset()), "\r\n")
+
+		return csvRecord{err: withReason(MalformedRecordReason, err), text: text, pos: pos}, nil
+	}
+
+	if err != nil {
+		return csvRecord{}, err
+	}
+
+	pos.line, _ = reader.FieldPos(0)
+
+	return csvRecord{fields: fields, pos: pos, comma: reader.Comma}, nil
+}
+
+// A record is considered to be a header if none of its fields is an email, since every record of a leak must
+// contain one, and some of its fields are known email or password column names, or columns mapped by name.
+// Other records without an email are invalid records rather than headers.
+func isCSVHeader(record []string, columns ...string) bool {
+	if findCSVEmailColumn(record) >= 0 {
+		return false
+	}
+
+	if indexOfAnyColumn(record, emailColumnNames) >= 0 || indexOfAnyColumn(record, passwordColumnNames) >= 0 {
+		return true
+	}
+
+	for _, c := range columns {
+		c = strings.TrimSpace(c)
+
+		if _, err := strconv.Atoi(c); len(c) == 0 || err == nil {
+			continue
+		}
+
+		if indexOfColumn(record, c) >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func csvRecordToCredentials(record csvRecord, columns csvColumns) (Credentials, error) {
+	if record.err != nil {
+		return Credentials{}, record.err
+	}
+
+	fields := record.fields
+
+	if len(fields) <= columns.email || len(fields) <= columns.password {
+		err := fmt.Errorf("input incorrect. Record should contain email and password information")
+		return Credentials{}, withReason(MissingFieldsReason, err)
+	}
+
+	return newCredentials(fields[columns.email], fields[columns.password])
+}
+
+func (r csvRecord) position() recordPosition {
+	return r.pos
+}
+
+// Only malformed records are kept as text, so the fields of the others are encoded again.
+func (r csvRecord) raw() string {
+	if r.fields == nil {
+		return r.text
+	}
+
+	var sb strings.Builder
+
+	w := csv.NewWriter(&sb)
+	w.Comma = r.comma
+
+	if err := w.Write(r.fields); err != nil {
+		return ""
+	}
+
+	w.Flush()
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (in *csvInput) Read(b []byte) (int, error) {
+	n, err := in.r.Read(b)
+	in.buf = append(in.buf, b[:n]...)
+
+	return n, err
+}
+
+// Returns the text of the input between the offsets start and end, which were not discarded yet.
+func (in *csvInput) text(start int64, end int64) string {
+	return string(in.buf[start-in.offset : end-in.offset])
+}
+
+// Forgets the text of the input before the offset end.
+func (in *csvInput) discard(end int64) {
+	n := copy(in.buf, in.buf[end-in.offset:])
+	in.buf = in.buf[:n]
+	in.offset = end
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCanParseCSVWithHeader(t *testing.T) {
+	csv := "id,username,email,hash,ip\n1,user,test@aaa,dghf,127.0.0.1\n2,user2,fghj2@aaa,dghf,127.0.0.1"
+
+	leak, err := CollectLeakParse((CSVLeakParser{}).ParseStream(strings.NewReader(csv)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 2 {
+		t.Fatalf("CSV designated by the string below contains a header and two valid records, but got %v users\nString: %s", len(leak), csv)
+	}
+}
+
+func TestCanParseCSVWithoutHeader(t *testing.T) {
+	csv := "1,test@aaa,dghf\n2,fghj2@aaa,dghf"
+
+	leak, err := CollectLeakParse((CSVLeakParser{}).ParseStream(strings.NewReader(csv)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 2 {
+		t.Fatalf("CSV designated by the string below contains two valid records, but got %v users\nString: %s", len(leak), csv)
+	}
+}
+
+func TestCanParseCSVWithQuotedFieldsContainingSeparator(t *testing.T) {
+	csv := "mail,secret\n\"test@aaa\",\"dg,\"\"hf\"\"\""
+
+	leak, err := CollectLeakParse((CSVLeakParser{PasswordColumn: "secret"}).ParseStream(strings.NewReader(csv)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 1 {
+		t.Fatalf("CSV designated by the string below contains one valid record with quoted fields, but got %v users\nString: %s", len(leak), csv)
+	}
+}
+
+func TestCanParseCSVWithColumnsMappedByIndex(t *testing.T) {
+	csv := "dghf;x;test@aaa"
+
+	leak, err := CollectLeakParse((CSVLeakParser{EmailColumn: "2", PasswordColumn: "0", Comma: ';'}).ParseStream(strings.NewReader(csv)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 1 {
+		t.Fatalf("CSV designated by the string below contains one valid record, but got %v users\nString: %s", len(leak), csv)
+	}
+}
+
+func TestCannotParseCSVWithColumnMappedByNameWithoutHeader(t *testing.T) {
+	csv := "test@aaa,dghf"
+
+	_, err := CollectLeakParse((CSVLeakParser{EmailColumn: "email"}).ParseStream(strings.NewReader(csv)))
+
+	if len(err) != 1 {
+		t.Fatalf("CSV designated by the string below does not contain a header, so mapping columns by name should fail\nString: %s", csv)
+	}
+}
+
+func TestCannotParseCSVWithUnknownColumn(t *testing.T) {
+	csv := "email,password\ntest@aaa,dghf"
+
+	_, err := CollectLeakParse((CSVLeakParser{PasswordColumn: "hash"}).ParseStream(strings.NewReader(csv)))
+
+	if len(err) != 1 {
+		t.Fatalf("CSV designated by the string below does not contain the mapped password column, so it should contain one error\nString: %s", csv)
+	}
+}
+
+func TestCanParseCSVWithSomeInvalidRecords(t *testing.T) {
+	csv := "email,password\ntest@aaa,dghf\nfghj2,dghf\nfghj2@aaa\n\"fghj2@aaa,dghf"
+
+	leak, err := CollectLeakParse((CSVLeakParser{}).ParseStream(strings.NewReader(csv)))
+
+	if len(leak) != 1 {
+		t.Fatalf("CSV designated by the string below contains one valid record, but got %v users\nString: %s", len(leak), csv)
+	}
+
+	if len(err) != 3 {
+		t.Fatalf("CSV designated by the string below contains three invalid records, but got %v errors\nString: %s", len(err), csv)
+	}
+}
+
+func TestCanParseCSVWithoutHeaderWhoseFirstRecordIsInvalid(t *testing.T) {
+	csv := "1,fghj2,dghf\n2,test@aaa,dghf\n3,fghj2@aaa,dghf"
+
+	leak, err := CollectLeakParse((CSVLeakParser{}).ParseStream(strings.NewReader(csv)))
+
+	if len(leak) != 2 || leak[0].Email != "test@aaa" {
+		t.Fatalf("CSV designated by the string below contains two valid records after an invalid one, but got %v users\nString: %s", len(leak), csv)
+	}
+
+	var perr *ParseError
+
+	if len(err) != 1 || !errors.As(err[0], &perr) || perr.Line != 1 {
+		t.Fatalf("First record of CSV designated by the string below is not a header, so it should be reported as an error, but got %v\nString: %s", err, csv)
+	}
+}
+
+func TestCanParseCSVWithHeaderOfMappedColumns(t *testing.T) {
+	csv := "login,secret\ntest@aaa,dghf"
+
+	leak, err := CollectLeakParse((CSVLeakParser{EmailColumn: "login", PasswordColumn: "secret"}).ParseStream(strings.NewReader(csv)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 1 {
+		t.Fatalf("CSV designated by the string below contains a header of the mapped columns and one valid record, but got %v users\nString: %s", len(leak), csv)
+	}
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	UnknownReason ParseErrorReason = iota
+	MissingSeparatorReason
+	MissingFieldsReason
+	InvalidEmailReason
+	InvalidPasswordReason
+	EncodingReason
+	MalformedRecordReason
+)
+
+// Maximum number of characters of a record that are kept in a parse error excerpt.
+const MaxExcerptLength = 64
+
+const redactedRune = '*'
+
+var parseErrorReasonNames = map[ParseErrorReason]string{
+	UnknownReason:          "unknown",
+	MissingSeparatorReason: "missing separator",
+	MissingFieldsReason:    "missing fields",
+	InvalidEmailReason:     "invalid email",
+	InvalidPasswordReason:  "invalid password",
+	EncodingReason:         "encoding",
+	MalformedRecordReason:  "malformed record",
+}
+
+type ParseErrorReason int
+
+// ParseError describes why a single record of a leak could not be parsed. Line is 1-based and is 0 if the
+// parser does not track lines (e.g., JSON arrays). Offset is the 0-based position of the record in the
+// (uncompressed) leak source. Excerpt is a redacted version of the record, safe to be logged.
+type ParseError struct {
+	Err     error
+	File    string
+	Excerpt string
+	Offset  int64
+	Line    int
+	Reason  ParseErrorReason
+	raw     string
+}
+
+type reasonError struct {
+	err    error
+	reason ParseErrorReason
+}
+
+type recordPosition struct {
+	offset int64
+	line   int
+}
+
+// A leakEntry is a single record of a leak source, which can be turned into a ParseError if it is invalid.
+type leakEntry interface {
+	position() recordPosition
+	raw() string
+}
+
+func ParseErrorReasons() []ParseErrorReason {
+	reasons := make([]ParseErrorReason, len(parseErrorReasonNames))
+
+	for i := range reasons {
+		reasons[i] = ParseErrorReason(i)
+	}
+
+	return reasons
+}
+
+// CountParseErrors counts errors by reason. Errors that are not a ParseError are counted as UnknownReason.
+func CountParseErrors(errs []error) map[ParseErrorReason]int {
+	counts := map[ParseErrorReason]int{}
+
+	for _, err := range errs {
+		var perr *ParseError
+
+		if errors.As(err, &perr) {
+			counts[perr.Reason]++
+		} else {
+			counts[UnknownReason]++
+		}
+	}
+
+	return counts
+}
+
+func (r ParseErrorReason) String() string {
+	if name, ok := parseErrorReasonNames[r]; ok {
+		return name
+	}
+
+	return parseErrorReasonNames[UnknownReason]
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+
+	if len(e.File) != 0 {
+		sb.WriteString(e.File)
+		sb.WriteString(":")
+	}
+
+	if e.Line > 0 {
+		sb.WriteString(fmt.Sprintf("%d ", e.Line))
+	}
+
+	sb.WriteString(fmt.Sprintf("(byte %d): %s: %v", e.Offset, e.Reason, e.Err))
+
+	if len(e.Excerpt) != 0 {
+		sb.WriteString(fmt.Sprintf(" [%s]", e.Excerpt))
+	}
+
+	return sb.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Raw returns the record that could not be parsed, as it was read from the leak. Unlike Excerpt, it is not
+// redacted, so it should not be logged.
+func (e *ParseError) Raw() string {
+	return e.raw
+}
+
+func (e reasonError) Error() string {
+	return e.err.Error()
+}
+
+func (e reasonError) Unwrap() error {
+	return e.err
+}
+
+func withReason(reason ParseErrorReason, err error) error {
+	return reasonError{
+		err:    err,
+		reason: reason,
+	}
+}
+
+func newParseError(entry leakEntry, err error) *ParseError {
+	reason := UnknownReason
+
+	var rerr reasonError
+
+	if errors.As(err, &rerr) {
+		reason = rerr.reason
+		err = rerr.err
+	}
+
+	raw := entry.raw()
+	pos := entry.position()
+
+	return &ParseError{
+		Err:     err,
+		Excerpt: redact(raw),
+		Offset:  pos.offset,
+		Line:    pos.line,
+		Reason:  reason,
+		raw:     raw,
+	}
+}
+
+// Returns a copy of a record which only keeps the first character of each word, so that the structure of the
+// record is visible without leaking emails and passwords. The copy is truncated to MaxExcerptLength characters.
+func redact(raw string) string {
+	var sb strings.Builder
+
+	if !utf8.ValidString(raw) {
+		raw = strings.ToValidUTF8(raw, string(utf8.RuneError))
+	}
+
+	n := 0
+	inWord := false
+
+	for _, r := range raw {
+		if n == MaxExcerptLength {
+			sb.WriteString("...")
+			break
+		}
+
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		if isWordRune && inWord {
+			sb.WriteRune(redactedRune)
+		} else {
+			sb.WriteRune(r)
+		}
+
+		inWord = isWordRune
+		n++
+	}
+
+	return sb.String()
+}
+
+func validateEncoding(raw string) error {
+	if !utf8.ValidString(raw) {
+		return withReason(EncodingReason, fmt.Errorf("input incorrect. Record is not valid UTF-8"))
+	}
+
+	return nil
+}
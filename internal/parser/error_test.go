@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorLocatesInvalidLine(t *testing.T) {
+	lines := []string{"test@aaa:dghf", "fghj2@aaa:dghf", "fghj2aaa:dghf"}
+
+	_, errs := linesToLeakParse(lines)
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.Line != 3 || perr.Offset != 29 {
+		t.Fatalf("Third line starts at byte 29, but error located it at line %d byte %d", perr.Line, perr.Offset)
+	}
+
+	if perr.Reason != InvalidEmailReason {
+		t.Fatalf("Third line contains an invalid email, but error reason is %s", perr.Reason)
+	}
+
+	if perr.Raw() != lines[2] {
+		t.Fatalf("Raw line of error should be %s, but got %s", lines[2], perr.Raw())
+	}
+}
+
+func TestParseErrorLocatesLineLongerThanMaxLineSize(t *testing.T) {
+	long := "long@aaa:" + strings.Repeat("a", 2*MaxLineSize)
+	lines := []string{"test@aaa:dghf", long, "fghj2@aaa:dghf"}
+
+	leak, errs := linesToLeakParse(lines)
+
+	if len(leak) != 2 {
+		t.Fatalf("Lines around the line longer than MaxLineSize are valid, so they should be parsed, but got %v users", len(leak))
+	}
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.Line != 2 || perr.Offset != 14 || perr.Reason != MalformedRecordReason {
+		t.Fatalf("Second line starts at byte 14 and is longer than MaxLineSize, but error located it at line %d byte %d (%s)", perr.Line, perr.Offset, perr.Reason)
+	}
+
+	if perr.Raw() != long[:MaxExcerptLength] {
+		t.Fatalf("Raw line of error should be the first %d bytes of the line, but got %s", MaxExcerptLength, perr.Raw())
+	}
+}
+
+func TestParseErrorLocatesLinesEndedByCarriageReturn(t *testing.T) {
+	r := strings.NewReader("test@aaa:dghf\r\nfghj2@aaa\r\n")
+
+	_, errs := CollectLeakParse(PlainTextLeakParser{Separator: ColonSeparator}.ParseStream(r))
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.Line != 2 || perr.Offset != 15 {
+		t.Fatalf("Second line starts at byte 15, but error located it at line %d byte %d", perr.Line, perr.Offset)
+	}
+
+	if perr.Reason != MissingSeparatorReason {
+		t.Fatalf("Second line does not contain the separator, but error reason is %s", perr.Reason)
+	}
+}
+
+func TestParseErrorExcerptIsRedacted(t *testing.T) {
+	_, errs := linesToLeakParse([]string{"test@aaa:dghf", "secret:password:"})
+
+	perr := assertSingleParseError(t, errs)
+
+	if strings.Contains(perr.Excerpt, "secret") || strings.Contains(perr.Error(), "password") {
+		t.Fatalf("Error should not leak the line contents, but got %s", perr.Error())
+	}
+
+	if perr.Excerpt != "s*****:p*******:" {
+		t.Fatalf("Excerpt should keep the first character of each word, but got %s", perr.Excerpt)
+	}
+}
+
+func TestParseErrorReportsInvalidEncoding(t *testing.T) {
+	_, errs := linesToLeakParse([]string{"test@aaa:dghf", "fghj2@aaa:dg\xffhf"})
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.Reason != EncodingReason {
+		t.Fatalf("Second line is not valid UTF-8, but error reason is %s", perr.Reason)
+	}
+}
+
+func TestParseErrorLocatesCSVRecord(t *testing.T) {
+	r := strings.NewReader("email,password\ntest@aaa,dghf\n\"fghj2@aaa\",\n")
+
+	_, errs := CollectLeakParse(CSVLeakParser{}.ParseStream(r))
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.Line != 3 || perr.Offset != 29 {
+		t.Fatalf("Third record starts at byte 29, but error located it at line %d byte %d", perr.Line, perr.Offset)
+	}
+
+	if perr.Raw() != "fghj2@aaa," {
+		t.Fatalf("Raw record of error should be fghj2@aaa, but got %s", perr.Raw())
+	}
+}
+
+func TestParseErrorLocatesSQLRow(t *testing.T) {
+	dump := "INSERT INTO users (email, password) VALUES\n('test@aaa', 'dghf'),\n('fghj2aaa', 'dghf');\n"
+
+	_, errs := CollectLeakParse(SQLLeakParser{}.ParseStream(strings.NewReader(dump)))
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.Line != 3 || perr.Offset != int64(strings.LastIndex(dump, "(")) {
+		t.Fatalf("Second row starts at line 3, but error located it at line %d byte %d", perr.Line, perr.Offset)
+	}
+}
+
+func TestParseErrorLocatesNDJSONLine(t *testing.T) {
+	r := strings.NewReader("{\"email\": \"test@aaa\", \"password\": \"dghf\"}\n{\"email\": \"fghj2@aaa\"}\n")
+
+	_, errs := CollectLeakParse(JSONLeakParser{}.ParseStream(r))
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.Line != 2 || perr.Reason != MissingFieldsReason {
+		t.Fatalf("Second line is missing the password field, but got %s at line %d", perr.Reason, perr.Line)
+	}
+}
+
+func TestParseErrorIsNamedAfterLeakFile(t *testing.T) {
+	fp := writeInputTestFile(t, "leak.txt", []byte("test@aaa:dghf\nfghj2aaa:dghf\n"))
+
+	var named string
+
+	_, errs := (PlainTextLeakParser{FilePath: fp}).Parse(context.Background(), func(err error) {
+		var perr *ParseError
+
+		if errors.As(err, &perr) {
+			named = perr.File
+		}
+	})
+
+	perr := assertSingleParseError(t, errs)
+
+	if perr.File != fp || named != fp {
+		t.Fatalf("Error should be named after leak file %s, but got %s (callback %s)", fp, perr.File, named)
+	}
+}
+
+func TestCountParseErrorsGroupsByReason(t *testing.T) {
+	_, errs := linesToLeakParse([]string{"test@aaa:dghf", "fghj2aaa:dghf", "aaa:dghf", "fghj2@aaa:"})
+
+	counts := CountParseErrors(errs)
+
+	if counts[InvalidEmailReason] != 2 || counts[InvalidPasswordReason] != 1 {
+		t.Fatalf("Leak contains 2 invalid emails and 1 invalid password, but got %v", counts)
+	}
+}
+
+func assertSingleParseError(t *testing.T, errs []error) *ParseError {
+	if len(errs) != 1 {
+		t.Fatalf("Expected a single parse error, but got %v", errs)
+	}
+
+	var perr *ParseError
+
+	if !errors.As(errs[0], &perr) {
+		t.Fatalf("Expected a ParseError, but got %T (%v)", errs[0], errs[0])
+	}
+
+	return perr
+}
@@ -0,0 +1,339 @@
+package parser
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	plainInputFormat inputFormat = iota
+	gzipInputFormat
+	bzip2InputFormat
+	xzInputFormat
+	zstdInputFormat
+	zipInputFormat
+	tarInputFormat
+)
+
+// Maximum number of compression and archive layers that are unwrapped for a single leak source.
+const MaxInputLayers = 4
+
+const (
+	tarMagicOffset = 257
+	sniffLength    = tarMagicOffset + 8
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic   = []byte{'P', 'K', 0x03, 0x04}
+	tarMagic   = []byte("ustar")
+)
+
+// Archive members that are never imported, unless explicitly included.
+var ignoredArchiveMembers = []string{"__MACOSX/*"}
+
+type inputFormat int
+
+// LeakSource is a single uncompressed file of a leak. A leak file maps to multiple sources if it is an archive.
+type LeakSource struct {
+	io.Reader
+	Name string
+}
+
+type OnLeakSourceCallback func(s LeakSource) error
+
+// ReadLeakSources opens the leak stored in filePath and calls cb for each of its sources. gzip, bzip2, xz and
+// zstd files are decompressed while being read, and members of zip and tar archives are handed out one at a time.
+// If include is not empty, only archive members that match the glob (either by full name or base name) are read.
+func ReadLeakSources(filePath string, include string, cb OnLeakSourceCallback) error {
+	file, err := openLeakFile(context.Background(), filePath)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return readLeakFileSources(file, filePath, include, cb)
+}
+
+func readLeakFileSources(file *leakFile, filePath string, include string, cb OnLeakSourceCallback) error {
+	br := bufio.NewReaderSize(file, sniffLength)
+
+	if sniffInputFormat(br) == zipInputFormat {
+		return readZipSources(file, file.size, include, cb)
+	}
+
+	return readSources(LeakSource{Reader: br, Name: filePath}, include, cb, 0)
+}
+
+// Parses every source of the leak stored in filePath, collecting its users and errors.
+func parseLeakFile(ctx context.Context, filePath string, include string, input io.Writer, p LeakStreamParser, pcb OnParseProgressCallback, ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
+	var leak query.LeakParse
+	var errors []error
+
+	_ = parseLeakFileBatches(ctx, filePath, include, input, p, pcb, func(b LeakParseBatch) error {
+		leak = append(leak, b.LeakParse...)
+		errors = append(errors, b.Errors...)
+
+		return nil
+	}, ecb...)
+
+	return leak, errors
+}
+
+// Parses every source of the leak stored in filePath, handing each batch to bcb as soon as it is parsed and
+// reporting the progress to pcb (if not nil) after each batch. Errors that are not about a record, such as a leak
+// file that can't be opened, are handed to bcb as a batch of their own. The content of each source is written to
+// input (if not nil) as it is parsed. The parse stops once ctx is done, or once bcb returns an error, which is
+// returned.
+func parseLeakFileBatches(ctx context.Context, filePath string, include string, input io.Writer, p LeakStreamParser, pcb OnParseProgressCallback, bcb OnLeakParseBatchCallback, ecb ...OnParseErrorCallback) error {
+	// The leak file is read with a context of its own, so that the parse can be stopped if bcb fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	file, err := openLeakFile(ctx, filePath)
+
+	if err != nil {
+		processOnParseError(err, ecb...)
+		return bcb(LeakParseBatch{LeakParse: query.LeakParse{}, Errors: []error{err}})
+	}
+
+	defer file.Close()
+
+	processOnParseProgress(file.progress(0), pcb)
+
+	records := 0
+
+	var berr error
+
+	err = readLeakFileSources(file, filePath, include, func(s LeakSource) error {
+		if input != nil {
+			s.Reader = io.TeeReader(s.Reader, input)
+		}
+
+		for b := range p.ParseStream(s, append([]OnParseErrorCallback{nameParseError(s.Name)}, ecb...)...) {
+			// Once bcb fails, reads of the leak file fail as well, and the batches left in the stream are
+			// drained so that its goroutines can exit.
+			if berr != nil {
+				continue
+			}
+
+			records += len(b.LeakParse) + len(b.Errors)
+
+			processOnParseProgress(file.progress(records), pcb)
+
+			if berr = bcb(b); berr != nil {
+				cancel()
+			}
+		}
+
+		return berr
+	})
+
+	if berr != nil {
+		return berr
+	}
+
+	if err != nil {
+		processOnParseError(err, ecb...)
+		return bcb(LeakParseBatch{LeakParse: query.LeakParse{}, Errors: []error{err}})
+	}
+
+	return nil
+}
+
+// Returns a callback that sets the file of parse errors to the name of the source they were found in. It must be
+// the first callback, so that the following ones see the file as well.
+func nameParseError(name string) OnParseErrorCallback {
+	return func(err error) {
+		var perr *ParseError
+
+		if errors.As(err, &perr) {
+			perr.File = name
+		}
+	}
+}
+
+func readSources(s LeakSource, include string, cb OnLeakSourceCallback, layer int) error {
+	if layer > MaxInputLayers {
+		return fmt.Errorf("%s contains more than %d compression or archive layers", s.Name, MaxInputLayers)
+	}
+
+	br := bufio.NewReaderSize(s.Reader, sniffLength)
+	format := sniffInputFormat(br)
+
+	switch format {
+	case plainInputFormat:
+		return cb(LeakSource{Reader: br, Name: s.Name})
+	case tarInputFormat:
+		return readTarSources(LeakSource{Reader: br, Name: s.Name}, include, cb, layer)
+	case zipInputFormat:
+		return fmt.Errorf("%s is a zip archive, which is only supported as the leak file itself", s.Name)
+	}
+
+	r, err := newDecompressor(format, br)
+
+	if err != nil {
+		return fmt.Errorf("could not decompress %s: %w", s.Name, err)
+	}
+
+	defer r.Close()
+
+	return readSources(LeakSource{Reader: r, Name: s.Name}, include, cb, layer+1)
+}
+
+func readTarSources(s LeakSource, include string, cb OnLeakSourceCallback, layer int) error {
+	tr := tar.NewReader(s)
+
+	for {
+		header, err := tr.Next()
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", s.Name, err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !isIncludedMember(header.Name, include) {
+			continue
+		}
+
+		err = readSources(LeakSource{Reader: tr, Name: path.Join(s.Name, header.Name)}, include, cb, layer+1)
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readZipSources(r io.ReaderAt, size int64, include string, cb OnLeakSourceCallback) error {
+	zr, err := zip.NewReader(r, size)
+
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !isIncludedMember(f.Name, include) {
+			continue
+		}
+
+		err = readZipMember(f, include, cb)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readZipMember(f *zip.File, include string, cb OnLeakSourceCallback) error {
+	rc, err := f.Open()
+
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", f.Name, err)
+	}
+
+	defer rc.Close()
+
+	return readSources(LeakSource{Reader: rc, Name: f.Name}, include, cb, 1)
+}
+
+func isIncludedMember(name string, include string) bool {
+	if len(include) == 0 {
+		for _, ignored := range ignoredArchiveMembers {
+			if matchesMember(name, ignored) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return matchesMember(name, include)
+}
+
+func matchesMember(name string, glob string) bool {
+	if ok, _ := path.Match(glob, name); ok {
+		return true
+	}
+
+	if ok, _ := path.Match(glob, path.Base(name)); ok {
+		return true
+	}
+
+	return strings.HasSuffix(glob, "/*") && strings.HasPrefix(name, strings.TrimSuffix(glob, "*"))
+}
+
+func sniffInputFormat(br *bufio.Reader) inputFormat {
+	magic, _ := br.Peek(sniffLength)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzipInputFormat
+	case isBzip2(magic):
+		return bzip2InputFormat
+	case bytes.HasPrefix(magic, xzMagic):
+		return xzInputFormat
+	case bytes.HasPrefix(magic, zstdMagic):
+		return zstdInputFormat
+	case bytes.HasPrefix(magic, zipMagic):
+		return zipInputFormat
+	case len(magic) > tarMagicOffset && bytes.HasPrefix(magic[tarMagicOffset:], tarMagic):
+		return tarInputFormat
+	default:
+		return plainInputFormat
+	}
+}
+
+// Reports whether magic starts a bzip2 stream, whose "BZh" signature is followed by its block size, a digit from 1 to
+// 9, so that plain text which starts with "BZh" is not taken for one.
+func isBzip2(magic []byte) bool {
+	n := len(bzip2Magic)
+
+	return bytes.HasPrefix(magic, bzip2Magic) && len(magic) > n && magic[n] >= '1' && magic[n] <= '9'
+}
+
+func newDecompressor(format inputFormat, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case gzipInputFormat:
+		return gzip.NewReader(r)
+	case bzip2InputFormat:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case xzInputFormat:
+		xr, err := xz.NewReader(r)
+
+		return io.NopCloser(xr), err
+	case zstdInputFormat:
+		zr, err := zstd.NewReader(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression format (%d)", format)
+	}
+}
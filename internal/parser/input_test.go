@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const inputTestLeak = "test@aaa:dghf\nfghj2@aaa:dghf\n"
+
+func TestCanParseGzipLeak(t *testing.T) {
+	var b bytes.Buffer
+
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte(inputTestLeak))
+	panicOnError(err)
+	panicOnError(w.Close())
+
+	assertLeakFileParses(t, writeInputTestFile(t, "leak.txt.gz", b.Bytes()), "", 2)
+}
+
+func TestCanParseXzLeak(t *testing.T) {
+	var b bytes.Buffer
+
+	w, err := xz.NewWriter(&b)
+	panicOnError(err)
+	_, err = w.Write([]byte(inputTestLeak))
+	panicOnError(err)
+	panicOnError(w.Close())
+
+	assertLeakFileParses(t, writeInputTestFile(t, "leak.txt.xz", b.Bytes()), "", 2)
+}
+
+func TestCanParseZstdLeak(t *testing.T) {
+	var b bytes.Buffer
+
+	w, err := zstd.NewWriter(&b)
+	panicOnError(err)
+	_, err = w.Write([]byte(inputTestLeak))
+	panicOnError(err)
+	panicOnError(w.Close())
+
+	assertLeakFileParses(t, writeInputTestFile(t, "leak.txt.zst", b.Bytes()), "", 2)
+}
+
+func TestCanParseZipLeakMembersThatMatchInclude(t *testing.T) {
+	var b bytes.Buffer
+
+	w := zip.NewWriter(&b)
+
+	for _, name := range []string{"part1.txt", "dir/part2.txt", "readme.md"} {
+		f, err := w.Create(name)
+		panicOnError(err)
+		_, err = f.Write([]byte(inputTestLeak))
+		panicOnError(err)
+	}
+
+	panicOnError(w.Close())
+
+	assertLeakFileParses(t, writeInputTestFile(t, "leak.zip", b.Bytes()), "*.txt", 4)
+}
+
+func TestCanParseTarGzipLeak(t *testing.T) {
+	var b bytes.Buffer
+
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+
+	for _, name := range []string{"part1.txt", "part2.txt"} {
+		panicOnError(tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(inputTestLeak)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(inputTestLeak))
+		panicOnError(err)
+	}
+
+	panicOnError(tw.Close())
+	panicOnError(gw.Close())
+
+	assertLeakFileParses(t, writeInputTestFile(t, "leak.tar.gz", b.Bytes()), "", 4)
+}
+
+func TestReadLeakSourcesHandsOutUncompressedPlainTextFileAsIs(t *testing.T) {
+	fp := writeInputTestFile(t, "leak.txt", []byte(inputTestLeak))
+
+	var contents []string
+
+	err := ReadLeakSources(fp, "", func(s LeakSource) error {
+		b, err := io.ReadAll(s)
+		contents = append(contents, string(b))
+
+		return err
+	})
+
+	panicOnError(err)
+
+	if len(contents) != 1 || contents[0] != inputTestLeak {
+		t.Fatalf("Leak file is an uncompressed plain text file, so it should be handed out as a single source with the same content, but got %v", contents)
+	}
+}
+
+func TestReadLeakSourcesHandsOutPlainTextFileThatStartsWithBzip2SignatureAsIs(t *testing.T) {
+	leak := "BZhang@aaa:dghf\n" + inputTestLeak
+	fp := writeInputTestFile(t, "leak.txt", []byte(leak))
+
+	var contents []string
+
+	err := ReadLeakSources(fp, "", func(s LeakSource) error {
+		b, err := io.ReadAll(s)
+		contents = append(contents, string(b))
+
+		return err
+	})
+
+	panicOnError(err)
+
+	if len(contents) != 1 || contents[0] != leak {
+		t.Fatalf("Leak file starts with \"BZh\" but not with a bzip2 block size, so it should be handed out as plain text, but got %v", contents)
+	}
+}
+
+func TestParseStopsOnceContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	leak, errs := (PlainTextLeakParser{FilePath: writeInputTestFile(t, "leak.txt", []byte(inputTestLeak))}).Parse(ctx)
+
+	if len(leak) != 0 || len(errs) == 0 || !errors.Is(errs[len(errs)-1], context.Canceled) {
+		t.Fatalf("Leak should not be parsed once the context is done, but got %v users (%v)", len(leak), errs)
+	}
+}
+
+func TestParseBatchesStopsOnceCallbackFails(t *testing.T) {
+	errStop := errors.New("database is locked")
+	fp := writeInputTestFile(t, "leak.txt", bytes.Repeat([]byte(inputTestLeak), 5*MaxLinesOfGoroutine))
+
+	batches := 0
+
+	err := (PlainTextLeakParser{FilePath: fp}).ParseBatches(context.Background(), func(b LeakParseBatch) error {
+		batches++
+		return errStop
+	})
+
+	if !errors.Is(err, errStop) || batches != 1 {
+		t.Fatalf("Parse should stop after the first batch with its error, but got %d batches (%v)", batches, err)
+	}
+}
+
+func writeInputTestFile(t *testing.T, name string, content []byte) string {
+	fp := filepath.Join(t.TempDir(), name)
+
+	panicOnError(os.WriteFile(fp, content, 0600))
+
+	return fp
+}
+
+func assertLeakFileParses(t *testing.T, fp string, include string, expected int) {
+	leak, err := (PlainTextLeakParser{FilePath: fp, Include: include}).Parse(context.Background())
+
+	panicOnErrors(err)
+
+	if len(leak) != expected {
+		t.Fatalf("Leak file %s contains %v valid lines, but got %v users", filepath.Base(fp), expected, len(leak))
+	}
+}
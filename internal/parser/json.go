@@ -0,0 +1,355 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/palavrapasse/damn/pkg/entity/query"
+)
+
+const (
+	DefaultJSONEmailField    = "email"
+	DefaultJSONPasswordField = "password"
+)
+
+const JSONFormat = "json"
+
+const (
+	jsonArrayConfidence         = 0.9
+	jsonObjectConfidence        = 0.95
+	jsonInvalidObjectConfidence = 0.3
+)
+
+const (
+	jsonArrayDelimiter     = '['
+	jsonObjectDelimiter    = '{'
+	jsonPathSeparator      = "."
+	jsonPointerSeparator   = "/"
+	jsonPointerEscapeSlash = "~1"
+	jsonPointerEscapeTilde = "~0"
+)
+
+// JSONLeakParser parses leaks stored either as a JSON array of objects or as newline delimited JSON objects.
+// Email and password are extracted using field paths, which can be written in dotted notation
+// (e.g., user.email) or as a JSON Pointer (e.g., /user/email).
+type JSONLeakParser struct {
+	FilePath      string
+	Include       string
+	EmailField    string
+	PasswordField string
+	Input         io.Writer
+	OnProgress    OnParseProgressCallback
+}
+
+type jsonRecord struct {
+	value any
+	err   error
+	text  string
+	pos   recordPosition
+}
+
+type jsonPath []string
+
+func init() {
+	RegisterLeakParser(LeakParserRegistration{
+		Name:       JSONFormat,
+		Extensions: []string{".json", ".ndjson", ".jsonl"},
+		Sniff:      sniffJSON,
+		Create: func(opts LeakParserOptions) LeakParser {
+			return JSONLeakParser{
+				FilePath:      opts.FilePath,
+				Include:       opts.Include,
+				EmailField:    opts.EmailField,
+				PasswordField: opts.PasswordField,
+				Input:         opts.Input,
+				OnProgress:    opts.OnProgress,
+			}
+		},
+	})
+}
+
+func (p JSONLeakParser) Parse(ctx context.Context, ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
+	return parseLeakFile(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, ecb...)
+}
+
+func (p JSONLeakParser) ParseBatches(ctx context.Context, bcb OnLeakParseBatchCallback, ecb ...OnParseErrorCallback) error {
+	return parseLeakFileBatches(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, bcb, ecb...)
+}
+
+func (p JSONLeakParser) ParseStream(r io.Reader, ecb ...OnParseErrorCallback) <-chan LeakParseBatch {
+	produce, convert, err := p.pipeline(r)
+
+	if err != nil {
+		return errorLeakParseStream(err, ecb...)
+	}
+
+	return streamLeakParse(produce, convert, ecb...)
+}
+
+func (p JSONLeakParser) Preview(r io.Reader, limit int) ([]ParsedRecord, error) {
+	return previewLeakParse(r, p.pipeline, limit)
+}
+
+func (p JSONLeakParser) pipeline(r io.Reader) (func(emit func(jsonRecord)) error, func(jsonRecord) (Credentials, error), error) {
+	emailPath, err := newJSONPath(p.EmailField, DefaultJSONEmailField)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passwordPath, err := newJSONPath(p.PasswordField, DefaultJSONPasswordField)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(r)
+	delimiter, err := peekJSONDelimiter(br)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var produce func(emit func(jsonRecord)) error
+
+	if delimiter == jsonArrayDelimiter {
+		produce = func(emit func(jsonRecord)) error {
+			return produceJSONArray(br, emit)
+		}
+	} else {
+		produce = func(emit func(jsonRecord)) error {
+			return produceNDJSON(br, emit)
+		}
+	}
+
+	convert := func(record jsonRecord) (Credentials, error) {
+		return jsonRecordToCredentials(record, emailPath, passwordPath)
+	}
+
+	return produce, convert, nil
+}
+
+// JSON is detected by its first delimiter. Leaks starting with an object are only parsed if each object is stored
+// in a single line, so the first line must be a valid JSON object.
+func sniffJSON(sample []byte) float64 {
+	trimmed := bytes.TrimSpace(sample)
+
+	if len(trimmed) == 0 {
+		return 0
+	}
+
+	switch trimmed[0] {
+	case jsonArrayDelimiter:
+		return jsonArrayConfidence
+	case jsonObjectDelimiter:
+		line := trimmed
+
+		if i := bytes.IndexByte(trimmed, '\n'); i >= 0 {
+			line = trimmed[:i]
+		}
+
+		if json.Valid(line) {
+			return jsonObjectConfidence
+		}
+
+		return jsonInvalidObjectConfidence
+	default:
+		return 0
+	}
+}
+
+func peekJSONDelimiter(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+
+		if errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("can't process empty leak")
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case jsonArrayDelimiter, jsonObjectDelimiter:
+			return b, br.UnreadByte()
+		default:
+			return 0, fmt.Errorf("input incorrect. JSON leak should start with an array or an object, but found %q", b)
+		}
+	}
+}
+
+func produceJSONArray(r io.Reader, emit func(jsonRecord)) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		var value any
+
+		pos := recordPosition{offset: decoder.InputOffset()}
+
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("input incorrect. Could not decode JSON array element: %w", err)
+		}
+
+		emit(jsonRecord{value: value, pos: pos})
+	}
+
+	_, err := decoder.Token()
+
+	return err
+}
+
+func produceNDJSON(r io.Reader, emit func(jsonRecord)) error {
+	scanner := newLineScanner(r)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+
+		if len(line) == 0 {
+			continue
+		}
+
+		var value any
+
+		record := jsonRecord{text: string(line), pos: scanner.line().pos}
+
+		if err := scanner.lineErr(); err != nil {
+			record.err = err
+			emit(record)
+
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&value); err != nil {
+			record.err = withReason(MalformedRecordReason, fmt.Errorf("input incorrect. Line is not a valid JSON object: %w", err))
+		} else {
+			record.value = value
+		}
+
+		emit(record)
+	}
+
+	return scanner.Err()
+}
+
+func jsonRecordToCredentials(record jsonRecord, emailPath jsonPath, passwordPath jsonPath) (Credentials, error) {
+	if record.err != nil {
+		return Credentials{}, record.err
+	}
+
+	email, err := emailPath.lookupString(record.value)
+
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	password, err := passwordPath.lookupString(record.value)
+
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	return newCredentials(email, password)
+}
+
+func newJSONPath(field string, defaultField string) (jsonPath, error) {
+	field = strings.TrimSpace(field)
+
+	if len(field) == 0 {
+		field = defaultField
+	}
+
+	if strings.HasPrefix(field, jsonPointerSeparator) {
+		tokens := strings.Split(field[1:], jsonPointerSeparator)
+
+		for i, t := range tokens {
+			t = strings.ReplaceAll(t, jsonPointerEscapeSlash, jsonPointerSeparator)
+			tokens[i] = strings.ReplaceAll(t, jsonPointerEscapeTilde, "~")
+		}
+
+		return jsonPath(tokens), nil
+	}
+
+	tokens := strings.Split(field, jsonPathSeparator)
+
+	for _, t := range tokens {
+		if len(t) == 0 {
+			return nil, fmt.Errorf("field path %s should not contain empty segments", field)
+		}
+	}
+
+	return jsonPath(tokens), nil
+}
+
+func (jp jsonPath) lookupString(value any) (string, error) {
+	current := value
+
+	for _, t := range jp {
+		var found bool
+
+		switch v := current.(type) {
+		case map[string]any:
+			current, found = v[t]
+		case []any:
+			i, err := strconv.Atoi(t)
+			found = err == nil && i >= 0 && i < len(v)
+
+			if found {
+				current = v[i]
+			}
+		}
+
+		if !found {
+			return "", withReason(MissingFieldsReason, fmt.Errorf("input incorrect. Record does not contain field %s", jp))
+		}
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", withReason(MalformedRecordReason, fmt.Errorf("input incorrect. Field %s of record should be a string", jp))
+	}
+}
+
+func (r jsonRecord) position() recordPosition {
+	return r.pos
+}
+
+// Records of JSON arrays are not kept as text, so they are encoded again.
+func (r jsonRecord) raw() string {
+	if len(r.text) != 0 || r.value == nil {
+		return r.text
+	}
+
+	b, err := json.Marshal(r.value)
+
+	if err != nil {
+		return ""
+	}
+
+	return string(b)
+}
+
+func (jp jsonPath) String() string {
+	return strings.Join(jp, jsonPathSeparator)
+}
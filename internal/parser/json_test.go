@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanParseJSONArray(t *testing.T) {
+	json := `[{"email": "test@aaa", "password": "dghf"}, {"email": "fghj2@aaa", "password": 1234}]`
+
+	leak, err := CollectLeakParse((JSONLeakParser{}).ParseStream(strings.NewReader(json)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 2 {
+		t.Fatalf("JSON designated by the string below contains two valid records, but got %v users\nString: %s", len(leak), json)
+	}
+}
+
+func TestCanParseNDJSON(t *testing.T) {
+	json := "{\"email\": \"test@aaa\", \"password\": \"dghf\"}\n\n{\"email\": \"fghj2@aaa\", \"password\": \"dghf\"}\n"
+
+	leak, err := CollectLeakParse((JSONLeakParser{}).ParseStream(strings.NewReader(json)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 2 {
+		t.Fatalf("NDJSON designated by the string below contains two valid records, but got %v users\nString: %s", len(leak), json)
+	}
+}
+
+func TestCanParseJSONWithDottedFieldPaths(t *testing.T) {
+	json := `[{"user": {"contacts": ["test@aaa"]}, "credentials": {"hash": "dghf"}}]`
+
+	leak, err := CollectLeakParse((JSONLeakParser{EmailField: "user.contacts.0", PasswordField: "credentials.hash"}).ParseStream(strings.NewReader(json)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 1 {
+		t.Fatalf("JSON designated by the string below contains one valid record, but got %v users\nString: %s", len(leak), json)
+	}
+}
+
+func TestCanParseJSONWithJSONPointerFieldPaths(t *testing.T) {
+	json := `{"user": {"e/mail": "test@aaa"}, "pass~word": "dghf"}`
+
+	leak, err := CollectLeakParse((JSONLeakParser{EmailField: "/user/e~1mail", PasswordField: "/pass~0word"}).ParseStream(strings.NewReader(json)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 1 {
+		t.Fatalf("JSON designated by the string below contains one valid record, but got %v users\nString: %s", len(leak), json)
+	}
+}
+
+func TestCanParseNDJSONWithSomeInvalidRecords(t *testing.T) {
+	json := "{\"email\": \"test@aaa\", \"password\": \"dghf\"}\n{\"email\": \"fghj2@aaa\"\n{\"email\": \"fghj2@aaa\"}\n{\"email\": true, \"password\": \"dghf\"}"
+
+	leak, err := CollectLeakParse((JSONLeakParser{}).ParseStream(strings.NewReader(json)))
+
+	if len(leak) != 1 {
+		t.Fatalf("NDJSON designated by the string below contains one valid record, but got %v users\nString: %s", len(leak), json)
+	}
+
+	if len(err) != 3 {
+		t.Fatalf("NDJSON designated by the string below contains three invalid records, but got %v errors\nString: %s", len(err), json)
+	}
+}
+
+func TestCanParseNDJSONWithRecordLongerThanMaxLineSize(t *testing.T) {
+	json := "{\"email\": \"test@aaa\", \"password\": \"dghf\"}\n{\"email\": \"fghj2@aaa\", \"password\": \"dghf\"}\n{\"email\": \"long@aaa\", \"password\": \"" + strings.Repeat("a", 2*MaxLineSize) + "\"}"
+
+	leak, err := CollectLeakParse((JSONLeakParser{}).ParseStream(strings.NewReader(json)))
+
+	if len(leak) != 2 || len(err) != 1 {
+		t.Fatalf("NDJSON contains two valid records followed by a record longer than MaxLineSize, but got %v users and %v errors", len(leak), err)
+	}
+}
+
+func TestCannotParseJSONThatIsNotAnArrayOrObject(t *testing.T) {
+	json := `"test@aaa:dghf"`
+
+	_, err := CollectLeakParse((JSONLeakParser{}).ParseStream(strings.NewReader(json)))
+
+	if len(err) != 1 {
+		t.Fatalf("JSON designated by the string below is neither an array or an object, so it should contain one error\nString: %s", json)
+	}
+}
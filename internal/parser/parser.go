@@ -1,13 +1,31 @@
 package parser
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/palavrapasse/damn/pkg/entity/query"
 )
 
+var emailColumnNames = []string{"email", "e-mail", "mail", "email_address", "emailaddress", "user_email", "useremail"}
+var passwordColumnNames = []string{"password", "pass", "passwd", "pwd", "hash", "password_hash", "passwordhash", "user_pass"}
+
 type OnParseErrorCallback func(err error)
 
+// Credentials are the validated email and password of a leak record.
+type Credentials struct {
+	Email    query.Email
+	Password query.Password
+}
+
+// A LeakParser parses a leak file into users. The parse stops once ctx is done, in which case the users and
+// errors parsed so far are returned. ParseBatches hands the users to bcb in batches instead, as soon as they are
+// parsed, so that the whole leak is never held in memory. It returns the error of bcb, if any.
 type LeakParser interface {
-	Parse(ecb ...OnParseErrorCallback) (query.LeakParse, []error)
+	Parse(ctx context.Context, ecb ...OnParseErrorCallback) (query.LeakParse, []error)
+	ParseBatches(ctx context.Context, bcb OnLeakParseBatchCallback, ecb ...OnParseErrorCallback) error
 }
 
 func processOnParseError(err error, ecb ...OnParseErrorCallback) {
@@ -15,3 +33,84 @@ func processOnParseError(err error, ecb ...OnParseErrorCallback) {
 		cb(err)
 	}
 }
+
+func (c Credentials) User() query.User {
+	return query.NewUser(c.Email)
+}
+
+func newCredentials(emailString string, passwordString string) (Credentials, error) {
+	if err := validateEncoding(emailString + passwordString); err != nil {
+		return Credentials{}, err
+	}
+
+	email, err := query.NewEmail(emailString)
+
+	if err != nil {
+		return Credentials{}, withReason(InvalidEmailReason, err)
+	}
+
+	password, err := query.NewPassword(passwordString)
+
+	if err != nil {
+		return Credentials{}, withReason(InvalidPasswordReason, err)
+	}
+
+	return Credentials{
+		Email:    email,
+		Password: password,
+	}, nil
+}
+
+// Returns the index of column, which can either be a zero based index or a column name. If column is empty,
+// named columns are searched for well known names. Returns -1 if the column could not be inferred.
+func findColumn(column string, columns []string, named bool, knownNames []string) (int, error) {
+	column = strings.TrimSpace(column)
+
+	if len(column) == 0 {
+		if named {
+			return indexOfAnyColumn(columns, knownNames), nil
+		}
+
+		return -1, nil
+	}
+
+	if i, err := strconv.Atoi(column); err == nil {
+		if i < 0 {
+			return -1, fmt.Errorf("column index should not be negative (%d)", i)
+		}
+
+		return i, nil
+	}
+
+	if !named {
+		return -1, fmt.Errorf("column %s is mapped by name, but leak does not contain column names", column)
+	}
+
+	i := indexOfColumn(columns, column)
+
+	if i < 0 {
+		return -1, fmt.Errorf("column %s does not exist in %v", column, columns)
+	}
+
+	return i, nil
+}
+
+func indexOfAnyColumn(columns []string, names []string) int {
+	for _, n := range names {
+		if i := indexOfColumn(columns, n); i >= 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func indexOfColumn(columns []string, name string) int {
+	for i, c := range columns {
+		if strings.EqualFold(strings.TrimSpace(c), name) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -2,11 +2,13 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"math"
-	"os"
+	"io"
+	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/palavrapasse/damn/pkg/entity/query"
 )
@@ -21,34 +23,216 @@ const (
 	CommaSeparator     = ","
 	ColonSeparator     = ":"
 	SemiColonSeparator = ";"
+	TabSeparator       = "\t"
+	PipeSeparator      = "|"
 )
 
+const tabSeparatorName = "tab"
+
 const MaxLinesOfGoroutine = 5000
 
-var supportedSeparators = []string{ColonSeparator, CommaSeparator, SemiColonSeparator}
+const PlainTextFormat = "plaintext"
+
+// Confidence of plain text detection when every sampled line is valid.
+const plainTextMaxConfidence = 0.8
+
+// Lines longer than this are reported as an error instead of being parsed. Only their first MaxExcerptLength bytes
+// are kept.
+const MaxLineSize = 1024 * 1024
+
+// Number of lines that are sampled in order to infer the separator of a leak.
+const SeparatorSampleSize = 1000
 
+var supportedSeparators = []string{ColonSeparator, CommaSeparator, SemiColonSeparator, TabSeparator, PipeSeparator}
+
+// PlainTextLeakParser parses leaks where each line contains an email and a password, split by a separator.
+// If Separator is empty, it is inferred from a sample of lines. If SeparatorFallback is set, lines that can't
+// be parsed with the separator are retried with every other supported separator.
 type PlainTextLeakParser struct {
-	FilePath string
+	FilePath          string
+	Include           string
+	Separator         string
+	SeparatorFallback bool
+	Input             io.Writer
+	OnProgress        OnParseProgressCallback
+}
+
+type plainTextLine struct {
+	err  error
+	text string
+	pos  recordPosition
+}
+
+// lineScanner is a bufio.Scanner that keeps track of the number and offset of the last scanned line. Lines longer
+// than MaxLineSize are discarded up to their end, instead of failing the scan, and are scanned as their first bytes.
+type lineScanner struct {
+	*bufio.Scanner
+	prefix   []byte
+	number   int
+	offset   int64
+	next     int64
+	skipped  int64
+	advance  int64
+	skipping bool
+	long     bool
+}
+
+func init() {
+	RegisterLeakParser(LeakParserRegistration{
+		Name:       PlainTextFormat,
+		Extensions: []string{".txt", ".lst"},
+		Sniff:      sniffPlainText,
+		Create: func(opts LeakParserOptions) LeakParser {
+			return PlainTextLeakParser{
+				FilePath:          opts.FilePath,
+				Include:           opts.Include,
+				Separator:         opts.Separator,
+				SeparatorFallback: opts.SeparatorFallback,
+				Input:             opts.Input,
+				OnProgress:        opts.OnProgress,
+			}
+		},
+	})
 }
 
-type linesParseResult struct {
-	query.LeakParse
-	errors []error
+func (p PlainTextLeakParser) Parse(ctx context.Context, ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
+	return parseLeakFile(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, ecb...)
 }
 
-func (p PlainTextLeakParser) Parse(ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
-	var errors []error
+func (p PlainTextLeakParser) ParseBatches(ctx context.Context, bcb OnLeakParseBatchCallback, ecb ...OnParseErrorCallback) error {
+	return parseLeakFileBatches(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, bcb, ecb...)
+}
 
-	lines, err := getFileLines(p.FilePath)
+func (p PlainTextLeakParser) ParseStream(r io.Reader, ecb ...OnParseErrorCallback) <-chan LeakParseBatch {
+	produce, convert, err := p.pipeline(r)
 
 	if err != nil {
-		processOnParseError(err, ecb...)
-		errors = append(errors, err)
+		return errorLeakParseStream(err, ecb...)
+	}
+
+	return streamLeakParse(produce, convert, ecb...)
+}
+
+func (p PlainTextLeakParser) Preview(r io.Reader, limit int) ([]ParsedRecord, error) {
+	return previewLeakParse(r, p.pipeline, limit)
+}
+
+func (p PlainTextLeakParser) pipeline(r io.Reader) (func(emit func(plainTextLine)) error, func(plainTextLine) (Credentials, error), error) {
+	scanner := newLineScanner(r)
+
+	var sample []plainTextLine
+
+	for len(sample) < SeparatorSampleSize && scanner.Scan() {
+		sample = append(sample, scanner.line())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(sample) == 0 {
+		return nil, nil, fmt.Errorf("can't process empty leak")
+	}
+
+	separator := p.Separator
+
+	if len(separator) == 0 {
+		var err error
+
+		separator, err = inferSeparator(plainTextLinesText(sample))
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	produce := func(emit func(plainTextLine)) error {
+		for _, line := range sample {
+			emit(line)
+		}
+
+		for scanner.Scan() {
+			emit(scanner.line())
+		}
+
+		return scanner.Err()
+	}
+
+	convert := func(line plainTextLine) (Credentials, error) {
+		if line.err != nil {
+			return Credentials{}, line.err
+		}
+
+		c, err := lineToCredentials(line.text, separator)
+
+		if err != nil && p.SeparatorFallback {
+			c, err = lineToCredentialsWithFallback(line.text, separator, err)
+		}
+
+		return c, err
+	}
+
+	return produce, convert, nil
+}
+
+// DetectSeparator samples the first source of the leak and infers its separator, the same way ParseStream does.
+func DetectSeparator(filePath string, include string) (string, error) {
+	var separator string
+
+	err := ReadLeakSources(filePath, include, func(s LeakSource) error {
+		scanner := newLineScanner(s)
+
+		var sample []string
+
+		for len(sample) < SeparatorSampleSize && scanner.Scan() {
+			sample = append(sample, scanner.Text())
+		}
+
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+
+		var err error
+
+		separator, err = inferSeparator(sample)
+
+		if err != nil {
+			return err
+		}
+
+		return errSampleRead
+	})
+
+	if err != nil && !errors.Is(err, errSampleRead) {
+		return "", err
+	}
+
+	return separator, nil
+}
+
+// Plain text confidence is given by the ratio of sampled lines that can be parsed.
+func sniffPlainText(sample []byte) float64 {
+	lines := sampleLines(sample)
+
+	if len(lines) == 0 {
+		return 0
+	}
+
+	valid := 0
+
+	for _, line := range lines {
+		separator, err := findSeparator(line)
+
+		if err == nil {
+			_, err = lineToCredentials(line, separator)
+		}
 
-		return nil, errors
+		if err == nil {
+			valid++
+		}
 	}
 
-	return linesToLeakParse(lines, ecb...)
+	return plainTextMaxConfidence * float64(valid) / float64(len(lines))
 }
 
 func findSeparator(line string) (string, error) {
@@ -59,143 +243,198 @@ func findSeparator(line string) (string, error) {
 		}
 	}
 
-	err := fmt.Errorf("input incorrect. Line %v should contain a valid separator (%v)", line, strings.Join(supportedSeparators, " "))
-	return "", err
+	err := fmt.Errorf("input incorrect. Line should contain a valid separator (%v)", strings.Join(supportedSeparators, " "))
+	return "", withReason(MissingSeparatorReason, err)
 }
 
-func lineToUser(line string, separator string) (query.User, error) {
+// Infers the separator of a leak from a sample of its lines. Each supported separator is scored by the number of
+// lines it is able to parse, and ties are broken by the number of lines that contain it. This way banners, comments
+// and lines using a different separator do not affect the inference, as long as they are a minority.
+func inferSeparator(lines []string) (string, error) {
+	var best string
+	bestValid, bestContained := 0, 0
 
-	if !strings.Contains(line, separator) {
-		err := fmt.Errorf("input incorrect. Line %v should the separator (%v)", line, separator)
-		return query.User{}, err
-	}
+	for _, separator := range supportedSeparators {
+		valid, contained := 0, 0
 
-	lineSplit := strings.Split(line, separator)
+		for _, line := range lines {
+			if !strings.Contains(line, separator) {
+				continue
+			}
 
-	if len(lineSplit) < NumberPositions {
-		err := fmt.Errorf("input incorrect. Line %v should contain email and password information", line)
-		return query.User{}, err
+			contained++
+
+			if _, err := lineToCredentials(line, separator); err == nil {
+				valid++
+			}
+		}
+
+		if valid > bestValid || (valid == bestValid && contained > bestContained) {
+			best, bestValid, bestContained = separator, valid, contained
+		}
 	}
 
-	emailString := string(lineSplit[EmailPosition])
-	email, err := query.NewEmail(emailString)
+	if bestContained == 0 {
+		err := fmt.Errorf("input incorrect. None of the first %d lines contain a valid separator (%v)", len(lines), strings.Join(supportedSeparators, " "))
+		return "", err
+	}
 
-	if err != nil {
-		return query.User{}, err
+	return best, nil
+}
+
+// Retries to parse a line with every supported separator other than the one that failed. If none succeeds, the
+// original error is returned.
+func lineToCredentialsWithFallback(line string, failed string, err error) (Credentials, error) {
+	for _, separator := range supportedSeparators {
+		if separator == failed {
+			continue
+		}
+
+		if u, errFallback := lineToCredentials(line, separator); errFallback == nil {
+			return u, nil
+		}
 	}
 
-	u := query.NewUser(email)
+	return Credentials{}, err
+}
 
-	password := string(strings.Join(lineSplit[PasswordPosition:], separator))
-	_, err = query.NewPassword(password)
+// UnescapeSeparator allows separators to be written using escape sequences (e.g., \t) or by name (tab).
+func UnescapeSeparator(separator string) string {
+	if strings.EqualFold(separator, tabSeparatorName) {
+		return TabSeparator
+	}
 
-	if err != nil {
-		return query.User{}, err
+	if unquoted, err := strconv.Unquote(`"` + separator + `"`); err == nil {
+		return unquoted
 	}
 
-	return u, nil
+	return separator
 }
 
-func linesToLeakParse(lines []string, ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
-	var errors []error
-	leak := query.LeakParse{}
+func lineToCredentials(line string, separator string) (Credentials, error) {
 
-	if len(lines) == 0 {
-		err := fmt.Errorf("can't process empty leak")
+	if !strings.Contains(line, separator) {
+		err := fmt.Errorf("input incorrect. Line should contain the separator (%q)", separator)
+		return Credentials{}, withReason(MissingSeparatorReason, err)
+	}
 
-		processOnParseError(err, ecb...)
-		errors = append(errors, err)
+	lineSplit := strings.Split(line, separator)
 
-		return leak, errors
+	if len(lineSplit) < NumberPositions {
+		err := fmt.Errorf("input incorrect. Line should contain email and password information")
+		return Credentials{}, withReason(MissingFieldsReason, err)
 	}
 
-	separator, err := findSeparator(lines[0])
+	emailString := string(lineSplit[EmailPosition])
+	password := string(strings.Join(lineSplit[PasswordPosition:], separator))
 
-	if err != nil {
-		processOnParseError(err, ecb...)
-		errors = append(errors, err)
+	return newCredentials(emailString, password)
+}
 
-		return leak, errors
-	}
+func linesToLeakParse(lines []string, ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
+	r := strings.NewReader(strings.Join(lines, "\n"))
 
-	nlines := len(lines)
+	return CollectLeakParse(PlainTextLeakParser{}.ParseStream(r, ecb...))
+}
 
-	ngoroutines := 1
-	if nlines > MaxLinesOfGoroutine {
-		ngoroutines = int(math.Ceil(float64(nlines) / float64(MaxLinesOfGoroutine)))
+func newLineScanner(r io.Reader) *lineScanner {
+	ls := &lineScanner{
+		Scanner: bufio.NewScanner(r),
 	}
 
-	linesParseResultChan := make(chan linesParseResult)
-
-	var wg sync.WaitGroup
+	ls.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MaxLineSize)
+	ls.Split(ls.split)
 
-	wg.Add(ngoroutines)
+	return ls
+}
 
-	for i := 0; i < ngoroutines; i++ {
+func (ls *lineScanner) split(data []byte, atEOF bool) (int, []byte, error) {
+	if ls.skipping {
+		i := bytes.IndexByte(data, '\n')
 
-		init := i * MaxLinesOfGoroutine
-		end := (i + 1) * MaxLinesOfGoroutine
-		if end > nlines {
-			end = nlines
+		if i < 0 && !atEOF {
+			ls.skipped += int64(len(data))
+			return len(data), nil, nil
 		}
 
-		go func(lines []string) {
+		advance := len(data)
+
+		if i >= 0 {
+			advance = i + 1
+		}
 
-			defer wg.Done()
-			linesParseResultChan <- routineLinesToLeakParse(lines, separator)
+		ls.skipping = false
+		ls.long = true
+		ls.advance = ls.skipped + int64(advance)
 
-		}(lines[init:end])
+		return advance, ls.prefix, nil
 	}
 
-	go func() {
-		wg.Wait()
-		close(linesParseResultChan)
-	}()
+	advance, token, err := bufio.ScanLines(data, atEOF)
+
+	// The buffer is full without a line in it, so the line is too long.
+	if token == nil && err == nil && len(data) >= MaxLineSize {
+		ls.skipping = true
+		ls.skipped = int64(len(data))
+		ls.prefix = append(ls.prefix[:0], data[:MaxExcerptLength]...)
+
+		return len(data), nil, nil
+	}
 
-	for s := range linesParseResultChan {
-		errors = append(errors, s.errors...)
-		leak = append(leak, s.LeakParse...)
+	if token != nil {
+		ls.long = false
+		ls.advance = int64(advance)
 	}
 
-	return leak, errors
+	return advance, token, err
 }
 
-func routineLinesToLeakParse(lines []string, separator string, ecb ...OnParseErrorCallback) linesParseResult {
-	leak := query.LeakParse{}
-	var errors []error
+func (ls *lineScanner) Scan() bool {
+	if !ls.Scanner.Scan() {
+		return false
+	}
 
-	for _, line := range lines {
+	ls.number++
+	ls.offset = ls.next
+	ls.next += ls.advance
 
-		user, err := lineToUser(line, separator)
+	return true
+}
 
-		if err == nil {
-			leak = append(leak, user)
-		} else {
-			processOnParseError(err, ecb...)
-			errors = append(errors, err)
-		}
+func (ls *lineScanner) line() plainTextLine {
+	return plainTextLine{
+		err:  ls.lineErr(),
+		text: ls.Text(),
+		pos: recordPosition{
+			offset: ls.offset,
+			line:   ls.number,
+		},
 	}
+}
 
-	return linesParseResult{
-		LeakParse: leak,
-		errors:    errors,
+// Returns the error of the last scanned line, which is only set if it was too long to be parsed.
+func (ls *lineScanner) lineErr() error {
+	if !ls.long {
+		return nil
 	}
+
+	return withReason(MalformedRecordReason, fmt.Errorf("input incorrect. Line is longer than %d bytes", MaxLineSize))
 }
 
-func getFileLines(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+func (l plainTextLine) position() recordPosition {
+	return l.pos
+}
+
+func (l plainTextLine) raw() string {
+	return l.text
+}
 
-	fileScanner := bufio.NewScanner(file)
-	fileScanner.Split(bufio.ScanLines)
-	var fileLines []string
+func plainTextLinesText(lines []plainTextLine) []string {
+	text := make([]string, len(lines))
 
-	for fileScanner.Scan() {
-		fileLines = append(fileLines, fileScanner.Text())
+	for i, l := range lines {
+		text[i] = l.text
 	}
 
-	return fileLines, nil
+	return text
 }
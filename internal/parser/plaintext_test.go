@@ -2,6 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -52,8 +54,77 @@ func TestCannotParseLinesToLeakWithFirstLineWithoutValidSeparator(t *testing.T)
 		t.Fatalf("Lines designated by the string below contains multiple lines which are invalid, but no error was identified\nString: %s", lines)
 	}
 
+	if len(lines) != len(err) {
+		t.Fatalf("The separator is inferred from all lines, so each of the %v invalid lines of Lines designated by the string below should contain one error, but got %v errors\nString: %s", len(lines), len(err), lines)
+	}
+}
+
+func TestCannotParseLinesToLeakWithoutValidSeparator(t *testing.T) {
+	lines := []string{"fghj2@aaa", "fghj2 dghf", "fghj2-dghf"}
+
+	_, err := linesToLeakParse(lines)
+
 	if len(err) != 1 {
-		t.Fatalf("The first line of Lines designated by the string below does not contain a valid separator so it should contain one error\nString: %s", lines)
+		t.Fatalf("None of the lines designated by the string below contains a valid separator so it should contain one error\nString: %s", lines)
+	}
+}
+
+func TestCanParseLinesToLeakWithBannerInFirstLines(t *testing.T) {
+	lines := []string{"# Leaked by: someone", "Total: 3 accounts", "test@aaa;dghf", "fghj2@aaa;dg:hf", "fghj3@aaa;dghf"}
+
+	leak, err := linesToLeakParse(lines)
+
+	if len(leak) != 3 {
+		t.Fatalf("Lines designated by the string below contains 3 valid lines after a banner, but got %v users\nString: %s", len(leak), lines)
+	}
+
+	if len(err) != 2 {
+		t.Fatalf("Lines designated by the string below contains a banner with 2 lines, but got %v errors\nString: %s", len(err), lines)
+	}
+}
+
+func TestCanParseLinesWithMixedSeparatorsUsingFallback(t *testing.T) {
+	lines := []string{"test@aaa:dghf", "fghj2@aaa|dghf", "fghj3@aaa\tdghf", "fghj4@aaa:dghf"}
+	r := strings.NewReader(strings.Join(lines, "\n"))
+
+	leak, err := CollectLeakParse((PlainTextLeakParser{SeparatorFallback: true}).ParseStream(r))
+
+	panicOnErrors(err)
+
+	if len(leak) != len(lines) {
+		t.Fatalf("Lines designated by the string below are all valid using a fallback separator, but got %v users\nString: %s", len(leak), lines)
+	}
+}
+
+func TestCannotParseLinesWithMixedSeparatorsWithoutFallback(t *testing.T) {
+	lines := []string{"test@aaa:dghf", "fghj2@aaa|dghf", "fghj3@aaa\tdghf", "fghj4@aaa:dghf"}
+	r := strings.NewReader(strings.Join(lines, "\n"))
+
+	leak, err := CollectLeakParse((PlainTextLeakParser{}).ParseStream(r))
+
+	if len(leak) != 2 || len(err) != 2 {
+		t.Fatalf("Lines designated by the string below contains 2 lines with a different separator, but got %v users and %v errors\nString: %s", len(leak), len(err), lines)
+	}
+}
+
+func TestCanParseLinesWithGivenSeparator(t *testing.T) {
+	lines := []string{"test@aaa||dg:hf", "fghj2@aaa||dg,hf"}
+	r := strings.NewReader(strings.Join(lines, "\n"))
+
+	leak, err := CollectLeakParse((PlainTextLeakParser{Separator: "||"}).ParseStream(r))
+
+	panicOnErrors(err)
+
+	if len(leak) != len(lines) {
+		t.Fatalf("Lines designated by the string below are all valid using || separator, but got %v users\nString: %s", len(leak), lines)
+	}
+}
+
+func TestCanUnescapeSeparator(t *testing.T) {
+	for separator, expected := range map[string]string{"\\t": TabSeparator, "tab": TabSeparator, "|": PipeSeparator, "::": "::"} {
+		if s := UnescapeSeparator(separator); s != expected {
+			t.Fatalf("Separator %s should be unescaped to %q, but got %q", separator, expected, s)
+		}
 	}
 }
 
@@ -145,3 +216,64 @@ func TestCanFindSemiColonSeparator(t *testing.T) {
 		t.Fatalf("A semicolon separator is present in line, but a different separator was found (%s)\n", testSep)
 	}
 }
+
+func TestCanParseStreamInMultipleBatches(t *testing.T) {
+	nlines := MaxLinesOfGoroutine*2 + 1
+	lines := make([]string, nlines)
+
+	for i := range lines {
+		lines[i] = fmt.Sprintf("user%d@aaa:dghf", i)
+	}
+
+	r := strings.NewReader(strings.Join(lines, "\n"))
+
+	nbatches := 0
+	nusers := 0
+
+	for b := range (PlainTextLeakParser{}).ParseStream(r) {
+		panicOnErrors(b.Errors)
+
+		nbatches++
+		nusers += len(b.LeakParse)
+	}
+
+	if nbatches != 3 {
+		t.Fatalf("Stream contains %v lines, so it should be handed out in 3 batches, but got %v batches", nlines, nbatches)
+	}
+
+	if nusers != nlines {
+		t.Fatalf("Stream contains %v valid lines, but only %v users were parsed", nlines, nusers)
+	}
+}
+
+func TestCanParseStreamWithFirstLineWithoutValidSeparator(t *testing.T) {
+	r := strings.NewReader("fghj2@aaa\ntest@aaa,dghf")
+
+	leak, err := CollectLeakParse((PlainTextLeakParser{}).ParseStream(r))
+
+	if len(err) != 1 {
+		t.Fatalf("The first line of the stream does not contain a valid separator so it should contain one error, but got %v errors", len(err))
+	}
+
+	if len(leak) != 1 {
+		t.Fatalf("The second line of the stream is valid so one user should be parsed, but got %v users", len(leak))
+	}
+}
+
+func TestParseStreamCallsErrorCallbackForEachInvalidLine(t *testing.T) {
+	r := strings.NewReader("test@aaa,dghf\nfghj2,dghf\n,dghf")
+
+	var mu sync.Mutex
+	ncalls := 0
+
+	_, err := CollectLeakParse((PlainTextLeakParser{}).ParseStream(r, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ncalls++
+	}))
+
+	if ncalls != len(err) {
+		t.Fatalf("Stream contains %v invalid lines, but the error callback was called %v times", len(err), ncalls)
+	}
+}
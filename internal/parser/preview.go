@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var errPreviewLimitReached = errors.New("preview limit reached")
+
+// ParsedRecord is a single record of a leak, along with the credentials parsed from it or the reason why it
+// could not be parsed. Excerpt is a redacted version of Raw, which hides passwords however they are quoted or
+// escaped in the record.
+type ParsedRecord struct {
+	Credentials
+	Err     *ParseError
+	File    string
+	Raw     string
+	Excerpt string
+	Offset  int64
+	Line    int
+}
+
+// previewReader is a source of a leak that is previewed. Reads fail once ctx is done, so that the preview stops.
+type previewReader struct {
+	io.Reader
+	ctx context.Context
+}
+
+// A LeakPreviewer parses the first records of a leak in order, one at a time, using the same pipeline as
+// ParseStream. Unlike ParseStream, the raw records are kept along with the result of parsing them.
+type LeakPreviewer interface {
+	Preview(r io.Reader, limit int) ([]ParsedRecord, error)
+}
+
+// PreviewLeakFile previews the first limit records of a leak file, going through its sources in order.
+func PreviewLeakFile(filePath string, include string, p LeakPreviewer, limit int) ([]ParsedRecord, error) {
+	var records []ParsedRecord
+
+	err := ReadLeakSources(filePath, include, func(s LeakSource) error {
+		rs, err := p.Preview(s, limit-len(records))
+
+		for i := range rs {
+			rs[i].File = s.Name
+
+			if rs[i].Err != nil {
+				rs[i].Err.File = s.Name
+			}
+		}
+
+		records = append(records, rs...)
+
+		if err != nil {
+			return err
+		}
+
+		if len(records) == limit {
+			return errPreviewLimitReached
+		}
+
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errPreviewLimitReached) {
+		return records, err
+	}
+
+	return records, nil
+}
+
+// Converts the entries that the pipeline of r emits until limit records have been parsed. Once the limit is
+// reached, reads of r fail, so that the producer stops without reading the rest of the leak, and the entries it
+// still emits are drained.
+func previewLeakParse[T leakEntry](r io.Reader, pipeline func(r io.Reader) (func(emit func(T)) error, func(T) (Credentials, error), error), limit int) ([]ParsedRecord, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	produce, convert, err := pipeline(&previewReader{Reader: r, ctx: ctx})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ParsedRecord
+
+	if limit <= 0 {
+		return records, nil
+	}
+
+	entries := make(chan T)
+
+	go func() {
+		defer close(entries)
+
+		err = produce(func(entry T) {
+			entries <- entry
+		})
+	}()
+
+	for entry := range entries {
+		if len(records) == limit {
+			continue
+		}
+
+		records = append(records, newParsedRecord(entry, convert))
+
+		if len(records) == limit {
+			cancel()
+		}
+	}
+
+	// The producer fails to read once the limit is reached, which is not an error of the preview.
+	if ctx.Err() != nil {
+		return records, nil
+	}
+
+	return records, err
+}
+
+func newParsedRecord[T leakEntry](entry T, convert func(T) (Credentials, error)) ParsedRecord {
+	pos := entry.position()
+
+	raw := entry.raw()
+
+	record := ParsedRecord{
+		Raw:     raw,
+		Excerpt: redact(raw),
+		Offset:  pos.offset,
+		Line:    pos.line,
+	}
+
+	c, err := convert(entry)
+
+	if err != nil {
+		record.Err = newParseError(entry, err)
+	} else {
+		record.Credentials = c
+	}
+
+	return record
+}
+
+func (r *previewReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.Reader.Read(b)
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreviewKeepsRawLinesInOrder(t *testing.T) {
+	lines := []string{"test@aaa:dghf", "fghj2aaa:dghf", "fghj2@aaa:dg:hf", "last@aaa:dghf"}
+
+	records, err := PlainTextLeakParser{}.Preview(strings.NewReader(strings.Join(lines, "\n")), 3)
+
+	panicOnError(err)
+
+	if len(records) != 3 {
+		t.Fatalf("Preview is limited to 3 records, but got %v", len(records))
+	}
+
+	for i, r := range records {
+		if r.Raw != lines[i] || r.Line != i+1 {
+			t.Fatalf("Record %d should be line %s, but got %s (line %d)", i, lines[i], r.Raw, r.Line)
+		}
+	}
+
+	if records[1].Err == nil || records[1].Err.Reason != InvalidEmailReason {
+		t.Fatalf("Second line contains an invalid email, but got %v", records[1].Err)
+	}
+
+	if records[2].Email != "fghj2@aaa" || records[2].Password != "dg:hf" {
+		t.Fatalf("Third line should be parsed as fghj2@aaa and dg:hf, but got %s and %s", records[2].Email, records[2].Password)
+	}
+}
+
+func TestPreviewRedactsQuotedAndEscapedPasswords(t *testing.T) {
+	previews := []struct {
+		p    LeakPreviewer
+		leak string
+	}{
+		{CSVLeakParser{}, "email,password,hint\na@b.com,\"pa,ss\"\"word\",\"pa,ss\"\"word\"\n"},
+		{SQLLeakParser{}, "INSERT INTO users (email, password) VALUES ('a@b.com', 'pa,ss\\'word');\n"},
+		{JSONLeakParser{}, "{\"email\": \"a@b.com\", \"password\": \"pa,ss\\\"\\u0077ord\"}\n"},
+	}
+
+	for _, preview := range previews {
+		records, err := preview.p.Preview(strings.NewReader(preview.leak), 1)
+
+		panicOnError(err)
+
+		if len(records) != 1 || records[0].Err != nil || records[0].Password != "pa,ss\"word" && records[0].Password != "pa,ss'word" {
+			t.Fatalf("Leak designated by the string below contains one valid record, but got %v\nString: %s", records, preview.leak)
+		}
+
+		if excerpt := records[0].Excerpt; strings.Contains(excerpt, "ss") || strings.Contains(excerpt, "ord") {
+			t.Fatalf("Excerpt of the record designated by the string below should hide its password, but got %s\nString: %s", excerpt, preview.leak)
+		}
+	}
+}
+
+func TestPreviewStopsReadingOnceLimitIsReached(t *testing.T) {
+	dump := mysqlDump + strings.Repeat("INSERT INTO `users` VALUES (9,'x@aaa','dghf');\n", 10000)
+
+	r := &countingReader{Reader: strings.NewReader(dump)}
+
+	records, err := SQLLeakParser{}.Preview(r, 1)
+
+	panicOnError(err)
+
+	if len(records) != 1 {
+		t.Fatalf("Preview is limited to 1 record, but got %v", len(records))
+	}
+
+	if read := r.read.Load(); read >= int64(len(dump)) {
+		t.Fatalf("Preview should stop reading the dump once the limit is reached, but read %d of %d bytes", read, len(dump))
+	}
+}
+
+func TestPreviewLeakFileGoesThroughSources(t *testing.T) {
+	fp := writeInputTestFile(t, "leak.txt", []byte(inputTestLeak))
+
+	records, err := PreviewLeakFile(fp, "", PlainTextLeakParser{}, 10)
+
+	panicOnError(err)
+
+	if len(records) != 2 || records[0].File != fp {
+		t.Fatalf("Leak file contains 2 lines, but got %v", records)
+	}
+}
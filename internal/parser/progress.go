@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+)
+
+// ParseProgress reports how much of a leak file has been parsed. BytesRead is the number of bytes read from the
+// leak file itself (before decompression), so that it can be compared with BytesTotal, the size of the file.
+// Records is the number of records parsed so far, either into users or into errors.
+type ParseProgress struct {
+	BytesRead  int64
+	BytesTotal int64
+	Records    int
+}
+
+type OnParseProgressCallback func(p ParseProgress)
+
+// leakFile is a leak file that counts how many bytes were read from it. Reads fail once ctx is done, so that the
+// parse of the leak stops.
+type leakFile struct {
+	*os.File
+	ctx  context.Context
+	read atomic.Int64
+	size int64
+}
+
+func openLeakFile(ctx context.Context, filePath string) (*leakFile, error) {
+	file, err := os.Open(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &leakFile{File: file, ctx: ctx, size: info.Size()}, nil
+}
+
+func (f *leakFile) Read(b []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.File.Read(b)
+	f.read.Add(int64(n))
+
+	return n, err
+}
+
+func (f *leakFile) ReadAt(b []byte, off int64) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.File.ReadAt(b, off)
+	f.read.Add(int64(n))
+
+	return n, err
+}
+
+// Returns the progress of a leak file of which records were parsed so far. The bytes read are capped by the size
+// of the file, since the directory of archives can be read more than once.
+func (f *leakFile) progress(records int) ParseProgress {
+	read := f.read.Load()
+
+	if read > f.size {
+		read = f.size
+	}
+
+	return ParseProgress{
+		BytesRead:  read,
+		BytesTotal: f.size,
+		Records:    records,
+	}
+}
+
+func processOnParseProgress(p ParseProgress, pcb OnParseProgressCallback) {
+	if pcb != nil {
+		pcb(p)
+	}
+}
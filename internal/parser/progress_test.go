@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+)
+
+func TestParseReportsProgressOfWholeLeakFile(t *testing.T) {
+	fp := writeInputTestFile(t, "leak.txt", []byte(inputTestLeak+"malformed\n"))
+
+	assertParseProgressEndsWith(t, fp, int64(len(inputTestLeak+"malformed\n")), 3)
+}
+
+func TestParseReportsProgressOfCompressedBytes(t *testing.T) {
+	var b bytes.Buffer
+
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte(inputTestLeak))
+	panicOnError(err)
+	panicOnError(w.Close())
+
+	assertParseProgressEndsWith(t, writeInputTestFile(t, "leak.txt.gz", b.Bytes()), int64(b.Len()), 2)
+}
+
+func assertParseProgressEndsWith(t *testing.T, fp string, size int64, records int) {
+	var progress []ParseProgress
+
+	p := PlainTextLeakParser{
+		FilePath: fp,
+		OnProgress: func(p ParseProgress) {
+			progress = append(progress, p)
+		},
+	}
+
+	p.Parse(context.Background())
+
+	if len(progress) < 2 {
+		t.Fatalf("Progress should be reported before and after parsing, but got %v", progress)
+	}
+
+	if first := progress[0]; first.Records != 0 || first.BytesTotal != size {
+		t.Fatalf("Progress should start with no records of %d bytes, but got %v", size, first)
+	}
+
+	if last := progress[len(progress)-1]; last.Records != records || last.BytesRead != size || last.BytesTotal != size {
+		t.Fatalf("Progress should end with %d records and %d bytes read, but got %v", records, size, last)
+	}
+}
@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const AutoFormat = "auto"
+
+// Number of bytes of the leak that are inspected in order to detect its format.
+const SniffSampleSize = 8 * 1024
+
+// Confidence added to a format whose extensions match the leak file name.
+const extensionConfidenceBonus = 0.1
+
+var compressionExtensions = []string{".gz", ".bz2", ".xz", ".zst"}
+
+var errSampleRead = errors.New("sample read")
+
+var (
+	registryMutex sync.RWMutex
+	registry      []LeakParserRegistration
+)
+
+// LeakParserOptions gathers the options of every registered leak parser. Each parser only uses the ones
+// it understands. If Input is not nil, the decompressed content of every source of the leak is written to it as
+// it is parsed.
+type LeakParserOptions struct {
+	FilePath          string
+	Include           string
+	EmailColumn       string
+	PasswordColumn    string
+	EmailField        string
+	PasswordField     string
+	Table             string
+	SQLDialect        string
+	Separator         string
+	SeparatorFallback bool
+	Input             io.Writer
+	OnProgress        OnParseProgressCallback
+}
+
+// A SniffFunc inspects the first bytes of a leak and returns how confident it is (between 0 and 1) that the leak
+// can be parsed by the parser it belongs to.
+type SniffFunc func(sample []byte) float64
+
+type LeakParserFactory func(opts LeakParserOptions) LeakParser
+
+type LeakParserRegistration struct {
+	Name       string
+	Extensions []string
+	Sniff      SniffFunc
+	Create     LeakParserFactory
+}
+
+type FormatDetection struct {
+	Format     string
+	Confidence float64
+}
+
+func RegisterLeakParser(r LeakParserRegistration) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for i, rr := range registry {
+		if rr.Name == r.Name {
+			registry[i] = r
+			return
+		}
+	}
+
+	registry = append(registry, r)
+}
+
+func RegisteredFormats() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	formats := make([]string, len(registry))
+
+	for i, r := range registry {
+		formats[i] = r.Name
+	}
+
+	sort.Strings(formats)
+
+	return formats
+}
+
+func NewLeakParser(format string, opts LeakParserOptions) (LeakParser, error) {
+	if r, ok := findLeakParserRegistration(format); ok {
+		return r.Create(opts), nil
+	}
+
+	return nil, fmt.Errorf("unknown leak format %s (supported: %s, %s)", format, AutoFormat, strings.Join(RegisteredFormats(), ", "))
+}
+
+func findLeakParserRegistration(format string) (LeakParserRegistration, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	for _, r := range registry {
+		if r.Name == format {
+			return r, true
+		}
+	}
+
+	return LeakParserRegistration{}, false
+}
+
+// DetectFormat samples the first source of the leak and returns the detections of every registered format,
+// sorted by descending confidence.
+func DetectFormat(filePath string, include string) ([]FormatDetection, error) {
+	var sample []byte
+
+	err := ReadLeakSources(filePath, include, func(s LeakSource) error {
+		b, err := io.ReadAll(io.LimitReader(s, SniffSampleSize))
+
+		if err != nil {
+			return err
+		}
+
+		sample = b
+
+		return errSampleRead
+	})
+
+	if err != nil && !errors.Is(err, errSampleRead) {
+		return nil, err
+	}
+
+	if len(sample) == 0 {
+		return nil, fmt.Errorf("can't detect format of empty leak")
+	}
+
+	return detectFormat(filePath, sample), nil
+}
+
+func detectFormat(filePath string, sample []byte) []FormatDetection {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	ext := leakFileExtension(filePath)
+	detections := make([]FormatDetection, len(registry))
+
+	for i, r := range registry {
+		confidence := r.Sniff(sample)
+
+		if confidence > 0 && containsString(r.Extensions, ext) {
+			confidence += extensionConfidenceBonus
+		}
+
+		if confidence > 1 {
+			confidence = 1
+		}
+
+		detections[i] = FormatDetection{
+			Format:     r.Name,
+			Confidence: confidence,
+		}
+	}
+
+	sort.SliceStable(detections, func(i, j int) bool {
+		return detections[i].Confidence > detections[j].Confidence
+	})
+
+	return detections
+}
+
+// Returns the lower case extension of the leak file, ignoring compression extensions (e.g., leak.csv.gz returns .csv).
+func leakFileExtension(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+
+	if containsString(compressionExtensions, ext) {
+		return leakFileExtension(strings.TrimSuffix(filePath, filepath.Ext(filePath)))
+	}
+
+	return ext
+}
+
+// Returns the complete lines of a sample, dropping the last one if it was cut by the sample size.
+func sampleLines(sample []byte) []string {
+	s := string(sample)
+
+	if len(sample) == SniffSampleSize {
+		if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+			s = s[:i]
+		}
+	}
+
+	var lines []string
+
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimRight(l, "\r")
+
+		if len(strings.TrimSpace(l)) != 0 {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines
+}
+
+func containsString(s []string, e string) bool {
+	for _, v := range s {
+		if v == e {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestDetectsPlainTextFormat(t *testing.T) {
+	assertDetectedFormat(t, "leak.txt", "test@aaa:dghf\nfghj2@aaa:dg:hf\n", PlainTextFormat)
+}
+
+func TestDetectsCSVFormatWithHeader(t *testing.T) {
+	assertDetectedFormat(t, "leak", "id,username,email,hash,ip\n1,user,test@aaa,dghf,127.0.0.1\n", CSVFormat)
+}
+
+func TestDetectsCSVFormatWithQuotedFields(t *testing.T) {
+	assertDetectedFormat(t, "leak.dat", "1,\"user, the first\",test@aaa,dghf\n2,user,fghj2@aaa,\"dg,hf\"\n", CSVFormat)
+}
+
+func TestDetectsJSONFormat(t *testing.T) {
+	assertDetectedFormat(t, "leak", "[\n  {\"email\": \"test@aaa\", \"password\": \"dghf\"}\n]", JSONFormat)
+}
+
+func TestDetectsNDJSONFormat(t *testing.T) {
+	assertDetectedFormat(t, "leak.gz", "{\"email\": \"test@aaa\", \"password\": \"dghf\"}\n{\"email\": \"fghj2@aaa\", \"password\": \"dghf\"}\n", JSONFormat)
+}
+
+func TestDetectsSQLFormat(t *testing.T) {
+	assertDetectedFormat(t, "leak", mysqlDump, SQLFormat)
+}
+
+func TestCannotCreateUnknownLeakParser(t *testing.T) {
+	_, err := NewLeakParser("xml", LeakParserOptions{})
+
+	if err == nil {
+		t.Fatalf("xml format is not registered, but no error was identified")
+	}
+}
+
+func TestCanCreateRegisteredLeakParsers(t *testing.T) {
+	for _, f := range RegisteredFormats() {
+		p, err := NewLeakParser(f, LeakParserOptions{})
+
+		panicOnError(err)
+
+		if p == nil {
+			t.Fatalf("%s format is registered, but no parser was created", f)
+		}
+	}
+}
+
+func assertDetectedFormat(t *testing.T, filePath string, sample string, expected string) {
+	detections := detectFormat(filePath, []byte(sample))
+
+	if detections[0].Format != expected {
+		t.Fatalf("Sample designated by the string below is in %s format, but detected %v\nString: %s", expected, detections, sample)
+	}
+
+	if len(detections) > 1 && detections[0].Confidence == detections[1].Confidence {
+		t.Fatalf("Sample designated by the string below is in %s format, but detection is ambiguous %v\nString: %s", expected, detections, sample)
+	}
+}
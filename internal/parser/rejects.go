@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TSVRejectsFormat    = "tsv"
+	NDJSONRejectsFormat = "ndjson"
+)
+
+var rejectsTSVHeader = []string{"file", "line", "offset", "reason", "raw"}
+
+var rejectsTSVEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// RejectsWriter writes every record that could not be parsed to w, along with the reason why, so that rejected
+// records can be fixed and imported again. Its OnParseError method can be used as an OnParseErrorCallback, which
+// is safe to be called concurrently. Errors that are not a ParseError are not written, as they do not belong to
+// a single record.
+type RejectsWriter struct {
+	mutex  sync.Mutex
+	w      *bufio.Writer
+	format string
+	count  int
+	err    error
+}
+
+type rejectedRecord struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Offset int64  `json:"offset"`
+	Reason string `json:"reason"`
+	Raw    string `json:"raw"`
+}
+
+func NewRejectsWriter(w io.Writer, format string) (*RejectsWriter, error) {
+	if format != TSVRejectsFormat && format != NDJSONRejectsFormat {
+		return nil, fmt.Errorf("unknown rejects format %s (supported: %s, %s)", format, TSVRejectsFormat, NDJSONRejectsFormat)
+	}
+
+	rw := &RejectsWriter{
+		w:      bufio.NewWriter(w),
+		format: format,
+	}
+
+	if format == TSVRejectsFormat {
+		_, rw.err = rw.w.WriteString(strings.Join(rejectsTSVHeader, "\t") + "\n")
+	}
+
+	return rw, nil
+}
+
+// RejectsFormatOfPath returns NDJSONRejectsFormat for files with a JSON extension, and TSVRejectsFormat otherwise.
+func RejectsFormatOfPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".ndjson", ".jsonl":
+		return NDJSONRejectsFormat
+	default:
+		return TSVRejectsFormat
+	}
+}
+
+func (rw *RejectsWriter) OnParseError(err error) {
+	var perr *ParseError
+
+	if !errors.As(err, &perr) {
+		return
+	}
+
+	record := rejectedRecord{
+		File:   perr.File,
+		Line:   perr.Line,
+		Offset: perr.Offset,
+		Reason: perr.Reason.String(),
+		Raw:    perr.Raw(),
+	}
+
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if rw.err != nil {
+		return
+	}
+
+	if rw.format == NDJSONRejectsFormat {
+		rw.err = rw.writeNDJSON(record)
+	} else {
+		rw.err = rw.writeTSV(record)
+	}
+
+	if rw.err == nil {
+		rw.count++
+	}
+}
+
+// Count returns the number of records written so far.
+func (rw *RejectsWriter) Count() int {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	return rw.count
+}
+
+// Flush writes any buffered record and returns the first error found while writing, if any.
+func (rw *RejectsWriter) Flush() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if rw.err != nil {
+		return rw.err
+	}
+
+	rw.err = rw.w.Flush()
+
+	return rw.err
+}
+
+func (rw *RejectsWriter) writeTSV(record rejectedRecord) error {
+	fields := []string{
+		rejectsTSVEscaper.Replace(record.File),
+		strconv.Itoa(record.Line),
+		strconv.FormatInt(record.Offset, 10),
+		record.Reason,
+		rejectsTSVEscaper.Replace(record.Raw),
+	}
+
+	_, err := rw.w.WriteString(strings.Join(fields, "\t") + "\n")
+
+	return err
+}
+
+func (rw *RejectsWriter) writeNDJSON(record rejectedRecord) error {
+	b, err := json.Marshal(record)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = rw.w.Write(append(b, '\n'))
+
+	return err
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRejectsWriterWritesRejectedLinesAsTSV(t *testing.T) {
+	var b bytes.Buffer
+
+	rw, err := NewRejectsWriter(&b, TSVRejectsFormat)
+	panicOnError(err)
+
+	_, errs := linesToLeakParse([]string{"test@aaa:dghf", "fghj2aaa:dg\thf"}, rw.OnParseError)
+	panicOnError(rw.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	if len(lines) != 2 || rw.Count() != len(errs) {
+		t.Fatalf("Rejects should contain a header and 1 rejected line, but got %v", lines)
+	}
+
+	expected := "\t2\t14\tinvalid email\tfghj2aaa:dg\\thf"
+
+	if lines[1] != expected {
+		t.Fatalf("Rejected line should be written as %q, but got %q", expected, lines[1])
+	}
+}
+
+func TestRejectsWriterWritesRejectedLinesAsNDJSON(t *testing.T) {
+	var b bytes.Buffer
+
+	rw, err := NewRejectsWriter(&b, NDJSONRejectsFormat)
+	panicOnError(err)
+
+	_, _ = linesToLeakParse([]string{"test@aaa:dghf", "fghj2aaa:dghf", "fghj2@aaa"}, rw.OnParseError)
+	panicOnError(rw.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("Rejects should contain 2 rejected lines, but got %v", lines)
+	}
+
+	var record rejectedRecord
+
+	for _, l := range lines {
+		panicOnError(json.Unmarshal([]byte(l), &record))
+
+		if record.Raw != "fghj2aaa:dghf" && record.Raw != "fghj2@aaa" {
+			t.Fatalf("Rejected line should contain the raw line, but got %v", record)
+		}
+	}
+}
+
+func TestRejectsWriterWritesMalformedCSVRecords(t *testing.T) {
+	var b bytes.Buffer
+
+	rw, err := NewRejectsWriter(&b, NDJSONRejectsFormat)
+	panicOnError(err)
+
+	csv := "email,password\ntest@aaa,dghf\nfghj2@aaa,dg\"hf\"x\nlast@aaa,dghf\n"
+
+	leak, _ := CollectLeakParse(CSVLeakParser{}.ParseStream(strings.NewReader(csv), rw.OnParseError))
+	panicOnError(rw.Flush())
+
+	var record rejectedRecord
+
+	panicOnError(json.Unmarshal(b.Bytes(), &record))
+
+	if len(leak) != 2 || rw.Count() != 1 || record.Raw != "fghj2@aaa,dg\"hf\"x" {
+		t.Fatalf("Rejects should contain the malformed CSV record as it was read, but got %v", record)
+	}
+}
+
+func TestCannotCreateRejectsWriterOfUnknownFormat(t *testing.T) {
+	_, err := NewRejectsWriter(&bytes.Buffer{}, "xml")
+
+	if err == nil {
+		t.Fatalf("xml rejects format is not supported, but no error was identified")
+	}
+}
+
+func TestRejectsFormatIsInferredFromPath(t *testing.T) {
+	if RejectsFormatOfPath("rejects.jsonl") != NDJSONRejectsFormat || RejectsFormatOfPath("rejects.txt") != TSVRejectsFormat {
+		t.Fatalf("Rejects format should be NDJSON for JSON files and TSV otherwise")
+	}
+}
@@ -0,0 +1,977 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/palavrapasse/damn/pkg/entity/query"
+)
+
+const SQLFormat = "sql"
+
+// SQL dialects of dumps, which decide how strings are escaped.
+const (
+	MySQLDialect      = "mysql"
+	PostgreSQLDialect = "postgresql"
+)
+
+const (
+	sqlInsertConfidence = 0.9
+	sqlCreateConfidence = 0.7
+)
+
+const (
+	sqlWordToken sqlTokenKind = iota
+	sqlStringToken
+	sqlQuotedIdentifierToken
+	sqlSymbolToken
+)
+
+const (
+	sqlCreateKeyword  = "CREATE"
+	sqlTableKeyword   = "TABLE"
+	sqlInsertKeyword  = "INSERT"
+	sqlReplaceKeyword = "REPLACE"
+	sqlIntoKeyword    = "INTO"
+	sqlValuesKeyword  = "VALUES"
+	sqlValueKeyword   = "VALUE"
+	sqlNullKeyword    = "NULL"
+	sqlSetKeyword     = "SET"
+)
+
+// PostgreSQL setting that turns off backslash escapes in strings, which pg_dump turns on at the start of its dumps.
+const sqlStandardConformingStrings = "standard_conforming_strings"
+
+const (
+	sqlOpenParenthesis  = "("
+	sqlCloseParenthesis = ")"
+	sqlComma            = ","
+	sqlDot              = "."
+	sqlSemiColon        = ";"
+)
+
+var SQLDialects = []string{MySQLDialect, PostgreSQLDialect}
+
+// Keywords that can precede a table name.
+var sqlTableNamePrefixKeywords = []string{"IF", "NOT", "EXISTS", "ONLY"}
+
+// Keywords that can start a CREATE TABLE definition which is not a column.
+var sqlConstraintKeywords = []string{"CONSTRAINT", "PRIMARY", "KEY", "INDEX", "UNIQUE", "FOREIGN", "CHECK", "FULLTEXT", "SPATIAL", "EXCLUDE", "LIKE", "PERIOD"}
+
+// SQLLeakParser parses MySQL and PostgreSQL dumps by tokenizing CREATE TABLE and INSERT statements, without
+// executing any SQL. Email and password columns are found by name, using the column list of the INSERT statement
+// or of the CREATE TABLE statement that precedes it, or inferred from the values of the rows if neither lists them.
+// If Table is empty, every table that contains both columns is imported. Table names are case insensitive.
+//
+// Backslashes in strings are escapes in MySQL dumps, but not in PostgreSQL dumps, except in escape strings
+// (E'...'). If Dialect is empty, strings are read as in MySQL until the dump turns on standard_conforming_strings,
+// as pg_dump does.
+type SQLLeakParser struct {
+	FilePath       string
+	Include        string
+	Table          string
+	EmailColumn    string
+	PasswordColumn string
+	Dialect        string
+	Input          io.Writer
+	OnProgress     OnParseProgressCallback
+}
+
+type sqlTokenKind int
+
+type sqlToken struct {
+	text string
+	kind sqlTokenKind
+	pos  recordPosition
+}
+
+type sqlTokenizer struct {
+	r                *sqlReader
+	backslashEscapes bool
+}
+
+// sqlReader is a bufio.Reader that keeps track of the position of the last byte read.
+type sqlReader struct {
+	*bufio.Reader
+	pos  recordPosition
+	last byte
+}
+
+type sqlRecord struct {
+	values  []string
+	columns sqlColumns
+	err     error
+	text    string
+	pos     recordPosition
+}
+
+type sqlColumns struct {
+	email    int
+	password int
+}
+
+// sqlDumpReader reads the rows of a dump. The columns of each table are kept in tables, or in inferred if they were
+// inferred from the values of its rows, by lower case table name. Tables whose columns could not be found are kept
+// in unknown.
+type sqlDumpReader struct {
+	parser    SQLLeakParser
+	tokenizer sqlTokenizer
+	tables    map[string][]string
+	inferred  map[string]sqlColumns
+	emit      func(sqlRecord)
+	unknown   []string
+	matches   int
+}
+
+func init() {
+	RegisterLeakParser(LeakParserRegistration{
+		Name:       SQLFormat,
+		Extensions: []string{".sql"},
+		Sniff:      sniffSQL,
+		Create: func(opts LeakParserOptions) LeakParser {
+			return SQLLeakParser{
+				FilePath:       opts.FilePath,
+				Include:        opts.Include,
+				Table:          opts.Table,
+				EmailColumn:    opts.EmailColumn,
+				PasswordColumn: opts.PasswordColumn,
+				Dialect:        opts.SQLDialect,
+				Input:          opts.Input,
+				OnProgress:     opts.OnProgress,
+			}
+		},
+	})
+}
+
+func (p SQLLeakParser) Parse(ctx context.Context, ecb ...OnParseErrorCallback) (query.LeakParse, []error) {
+	return parseLeakFile(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, ecb...)
+}
+
+func (p SQLLeakParser) ParseBatches(ctx context.Context, bcb OnLeakParseBatchCallback, ecb ...OnParseErrorCallback) error {
+	return parseLeakFileBatches(ctx, p.FilePath, p.Include, p.Input, p, p.OnProgress, bcb, ecb...)
+}
+
+func (p SQLLeakParser) ParseStream(r io.Reader, ecb ...OnParseErrorCallback) <-chan LeakParseBatch {
+	produce, convert, err := p.pipeline(r)
+
+	if err != nil {
+		return errorLeakParseStream(err, ecb...)
+	}
+
+	return streamLeakParse(produce, convert, ecb...)
+}
+
+func (p SQLLeakParser) Preview(r io.Reader, limit int) ([]ParsedRecord, error) {
+	return previewLeakParse(r, p.pipeline, limit)
+}
+
+func (p SQLLeakParser) pipeline(r io.Reader) (func(emit func(sqlRecord)) error, func(sqlRecord) (Credentials, error), error) {
+	if len(p.Dialect) != 0 && p.Dialect != MySQLDialect && p.Dialect != PostgreSQLDialect {
+		return nil, nil, fmt.Errorf("unsupported SQL dialect %s, expected one of %s", p.Dialect, strings.Join(SQLDialects, ", "))
+	}
+
+	produce := func(emit func(sqlRecord)) error {
+		d := sqlDumpReader{
+			parser:    p,
+			tokenizer: sqlTokenizer{r: newSQLReader(r), backslashEscapes: p.Dialect != PostgreSQLDialect},
+			tables:    map[string][]string{},
+			inferred:  map[string]sqlColumns{},
+			emit:      emit,
+		}
+
+		return d.read()
+	}
+
+	return produce, sqlRecordToCredentials, nil
+}
+
+func sniffSQL(sample []byte) float64 {
+	upper := strings.ToUpper(string(sample))
+
+	switch {
+	case strings.Contains(upper, sqlInsertKeyword+" "+sqlIntoKeyword):
+		return sqlInsertConfidence
+	case strings.Contains(upper, sqlCreateKeyword+" "+sqlTableKeyword):
+		return sqlCreateConfidence
+	default:
+		return 0
+	}
+}
+
+func sqlRecordToCredentials(record sqlRecord) (Credentials, error) {
+	if record.err != nil {
+		return Credentials{}, record.err
+	}
+
+	values := record.values
+	columns := record.columns
+
+	if len(values) <= columns.email || len(values) <= columns.password {
+		err := fmt.Errorf("input incorrect. Row should contain email and password information")
+		return Credentials{}, withReason(MissingFieldsReason, err)
+	}
+
+	return newCredentials(values[columns.email], values[columns.password])
+}
+
+func (r sqlRecord) position() recordPosition {
+	return r.pos
+}
+
+// Rows are not kept as text, so their values are encoded again as string literals.
+func (r sqlRecord) raw() string {
+	if r.values == nil {
+		return r.text
+	}
+
+	quoted := make([]string, len(r.values))
+
+	for i, v := range r.values {
+		quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+
+	return sqlOpenParenthesis + strings.Join(quoted, sqlComma+" ") + sqlCloseParenthesis
+}
+
+func (d *sqlDumpReader) read() error {
+	for {
+		token, err := d.tokenizer.next()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case token.isKeyword(sqlCreateKeyword):
+			err = d.readCreate()
+		case token.isKeyword(sqlInsertKeyword), token.isKeyword(sqlReplaceKeyword):
+			err = d.readInsert(token)
+		case token.isKeyword(sqlSetKeyword):
+			err = d.readSet()
+		case token.isSymbol(sqlSemiColon):
+			continue
+		default:
+			err = d.skipStatement()
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.matches == 0 && len(d.unknown) != 0 {
+		return fmt.Errorf("input incorrect. Dump does not contain INSERT statements with email and password columns, and the columns of tables %s are unknown", strings.Join(d.unknown, ", "))
+	}
+
+	if d.matches == 0 {
+		return fmt.Errorf("input incorrect. Dump does not contain INSERT statements with email and password columns")
+	}
+
+	return nil
+}
+
+// Reads CREATE TABLE statements in order to learn the columns of each table. Other CREATE statements are skipped.
+func (d *sqlDumpReader) readCreate() error {
+	for {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return err
+		}
+
+		if token.isSymbol(sqlSemiColon) {
+			return nil
+		}
+
+		if token.isSymbol(sqlOpenParenthesis) {
+			return d.skipStatement()
+		}
+
+		if token.isKeyword(sqlTableKeyword) {
+			break
+		}
+	}
+
+	table, last, err := d.readTableName()
+
+	if err != nil {
+		return err
+	}
+
+	if !last.isSymbol(sqlOpenParenthesis) {
+		if last.isSymbol(sqlSemiColon) {
+			return nil
+		}
+
+		return d.skipStatement()
+	}
+
+	var columns []string
+	startOfDefinition := true
+	depth := 1
+
+	for depth > 0 {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case token.isSymbol(sqlOpenParenthesis):
+			depth++
+		case token.isSymbol(sqlCloseParenthesis):
+			depth--
+		case depth == 1 && token.isSymbol(sqlComma):
+			startOfDefinition = true
+			continue
+		case startOfDefinition && depth == 1 && token.isIdentifier() && !token.isAnyKeyword(sqlConstraintKeywords):
+			columns = append(columns, token.text)
+		}
+
+		startOfDefinition = false
+	}
+
+	d.tables[strings.ToLower(table)] = columns
+
+	return d.skipStatement()
+}
+
+// Reads INSERT statements and emits each row of tables that contain email and password columns.
+func (d *sqlDumpReader) readInsert(insert sqlToken) error {
+	for {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return err
+		}
+
+		if token.isSymbol(sqlSemiColon) {
+			return nil
+		}
+
+		if token.isKeyword(sqlIntoKeyword) {
+			break
+		}
+	}
+
+	table, last, err := d.readTableName()
+
+	if err != nil {
+		return err
+	}
+
+	columns, known := d.tables[strings.ToLower(table)]
+
+	if last.isSymbol(sqlOpenParenthesis) {
+		columns, err = d.readColumnList()
+		known = true
+
+		if err != nil {
+			return err
+		}
+
+		last, err = d.tokenizer.next()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if !last.isKeyword(sqlValuesKeyword) && !last.isKeyword(sqlValueKeyword) {
+		return d.skipStatement()
+	}
+
+	if len(d.parser.Table) != 0 && !strings.EqualFold(d.parser.Table, table) {
+		return d.skipRows(nil)
+	}
+
+	if !known {
+		return d.readRowsOfUnknownColumns(insert, table)
+	}
+
+	mapping, err := d.findColumns(table, columns)
+
+	if err != nil {
+		if len(d.parser.Table) == 0 {
+			return d.skipRows(nil)
+		}
+
+		d.emit(sqlRecord{
+			err:  withReason(MissingFieldsReason, err),
+			text: fmt.Sprintf("%s %s %s", insert.text, sqlIntoKeyword, table),
+			pos:  insert.pos,
+		})
+
+		return d.skipRows(nil)
+	}
+
+	d.matches++
+
+	return d.skipRows(func(values []string, pos recordPosition) {
+		d.emit(sqlRecord{values: values, columns: mapping, pos: pos})
+	})
+}
+
+func (d *sqlDumpReader) findColumns(table string, columns []string) (sqlColumns, error) {
+	email, err := findColumn(d.parser.EmailColumn, columns, true, emailColumnNames)
+
+	if err == nil && email < 0 {
+		err = fmt.Errorf("input incorrect. Could not find email column in table %s %v", table, columns)
+	}
+
+	if err != nil {
+		return sqlColumns{}, err
+	}
+
+	password, err := findColumn(d.parser.PasswordColumn, columns, true, passwordColumnNames)
+
+	if err == nil && password < 0 {
+		err = fmt.Errorf("input incorrect. Could not find password column in table %s %v", table, columns)
+	}
+
+	if err != nil {
+		return sqlColumns{}, err
+	}
+
+	return sqlColumns{
+		email:    email,
+		password: password,
+	}, nil
+}
+
+// Reads SET statements in order to follow standard_conforming_strings, which decides whether backslashes in
+// strings are escapes. MySQL does not have it, so it is ignored in MySQL dumps.
+func (d *sqlDumpReader) readSet() error {
+	var tokens []sqlToken
+
+	for {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return err
+		}
+
+		if token.isSymbol(sqlSemiColon) {
+			break
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	if d.parser.Dialect == MySQLDialect {
+		return nil
+	}
+
+	// SET [ SESSION | LOCAL ] standard_conforming_strings { TO | = } { on | off }
+	for i := 0; i+2 < len(tokens); i++ {
+		if !tokens[i].isKeyword(sqlStandardConformingStrings) {
+			continue
+		}
+
+		switch strings.ToLower(tokens[i+2].text) {
+		case "on":
+			d.tokenizer.backslashEscapes = false
+		case "off":
+			d.tokenizer.backslashEscapes = true
+		}
+	}
+
+	return nil
+}
+
+// Reads the rows of an INSERT statement into a table whose columns are not listed, neither by the statement nor by
+// a CREATE TABLE statement. As in CSV records without a header, the email column is the first one whose value is an
+// email and the password column is the one after it, unless they are mapped by index. Rows are read ahead until the
+// columns are inferred, up to a chunk of them, and the columns are kept for the next statements of the table.
+func (d *sqlDumpReader) readRowsOfUnknownColumns(insert sqlToken, table string) error {
+	key := strings.ToLower(table)
+	mapping, inferred := d.inferred[key]
+
+	var ahead []sqlRecord
+
+	err := d.skipRows(func(values []string, pos recordPosition) {
+		if inferred {
+			d.emit(sqlRecord{values: values, columns: mapping, pos: pos})
+			return
+		}
+
+		if len(ahead) == MaxLinesOfGoroutine {
+			return
+		}
+
+		ahead = append(ahead, sqlRecord{values: values, pos: pos})
+		mapping, inferred = d.inferColumns(values)
+
+		if !inferred {
+			return
+		}
+
+		d.inferred[key] = mapping
+
+		for _, record := range ahead {
+			record.columns = mapping
+			d.emit(record)
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if inferred {
+		d.matches++
+		return nil
+	}
+
+	if len(d.parser.Table) == 0 {
+		if indexOfColumn(d.unknown, table) < 0 {
+			d.unknown = append(d.unknown, table)
+		}
+
+		return nil
+	}
+
+	err = fmt.Errorf("input incorrect. Could not find the columns of table %s, which are not listed and could not be inferred from its values", table)
+
+	d.emit(sqlRecord{
+		err:  withReason(MissingFieldsReason, err),
+		text: fmt.Sprintf("%s %s %s", insert.text, sqlIntoKeyword, table),
+		pos:  insert.pos,
+	})
+
+	return nil
+}
+
+// Infers the email and password columns of a table from the values of one of its rows, returning false if the
+// row does not contain an email.
+func (d *sqlDumpReader) inferColumns(values []string) (sqlColumns, bool) {
+	email, err := findColumn(d.parser.EmailColumn, values, false, emailColumnNames)
+
+	if err != nil {
+		return sqlColumns{}, false
+	}
+
+	if email < 0 {
+		email = findCSVEmailColumn(values)
+	}
+
+	password, err := findColumn(d.parser.PasswordColumn, values, false, passwordColumnNames)
+
+	if err != nil || email < 0 {
+		return sqlColumns{}, false
+	}
+
+	if password < 0 {
+		password = email + 1
+	}
+
+	if email == password {
+		return sqlColumns{}, false
+	}
+
+	return sqlColumns{
+		email:    email,
+		password: password,
+	}, true
+}
+
+// Reads a (possibly qualified) table name and returns its last part, along with the token that follows it.
+func (d *sqlDumpReader) readTableName() (string, sqlToken, error) {
+	token, err := d.tokenizer.next()
+
+	for err == nil && token.isAnyKeyword(sqlTableNamePrefixKeywords) {
+		token, err = d.tokenizer.next()
+	}
+
+	for {
+		if err != nil {
+			return "", token, err
+		}
+
+		if !token.isIdentifier() {
+			return "", token, fmt.Errorf("input incorrect. Expected table name, but found %s", token.text)
+		}
+
+		table := token.text
+		token, err = d.tokenizer.next()
+
+		if err != nil || !token.isSymbol(sqlDot) {
+			return table, token, err
+		}
+
+		token, err = d.tokenizer.next()
+	}
+}
+
+func (d *sqlDumpReader) readColumnList() ([]string, error) {
+	var columns []string
+
+	for {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return columns, err
+		}
+
+		if token.isSymbol(sqlCloseParenthesis) {
+			return columns, nil
+		}
+
+		if token.isIdentifier() {
+			columns = append(columns, token.text)
+		}
+	}
+}
+
+// Reads every row of a VALUES list until the end of the statement. Each row is handed to cb, if not nil, along
+// with its position.
+func (d *sqlDumpReader) skipRows(cb func([]string, recordPosition)) error {
+	for {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case token.isSymbol(sqlSemiColon):
+			return nil
+		case token.isSymbol(sqlOpenParenthesis):
+			values, err := d.readRow()
+
+			if err != nil {
+				return err
+			}
+
+			if cb != nil {
+				cb(values, token.pos)
+			}
+		case token.isSymbol(sqlComma):
+			continue
+		default:
+			return d.skipStatement()
+		}
+	}
+}
+
+func (d *sqlDumpReader) readRow() ([]string, error) {
+	var values []string
+	var value strings.Builder
+	var literal *string
+
+	depth := 1
+
+	appendValue := func() {
+		v := value.String()
+
+		if literal != nil {
+			v = *literal
+		} else if strings.EqualFold(v, sqlNullKeyword) {
+			v = ""
+		}
+
+		values = append(values, v)
+		value.Reset()
+		literal = nil
+	}
+
+	for {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return values, err
+		}
+
+		switch {
+		case token.isSymbol(sqlOpenParenthesis):
+			depth++
+		case token.isSymbol(sqlCloseParenthesis):
+			depth--
+
+			if depth == 0 {
+				appendValue()
+				return values, nil
+			}
+		case depth == 1 && token.isSymbol(sqlComma):
+			appendValue()
+			continue
+		}
+
+		if token.kind == sqlStringToken || token.kind == sqlQuotedIdentifierToken {
+			text := token.text
+			literal = &text
+		}
+
+		value.WriteString(token.text)
+	}
+}
+
+func (d *sqlDumpReader) skipStatement() error {
+	for {
+		token, err := d.tokenizer.next()
+
+		if err != nil {
+			return err
+		}
+
+		if token.isSymbol(sqlSemiColon) {
+			return nil
+		}
+	}
+}
+
+// Returns the next token of the dump, skipping white spaces and comments. Returns io.EOF once the dump has been
+// fully consumed.
+func (t sqlTokenizer) next() (sqlToken, error) {
+	for {
+		pos := t.r.pos
+		b, err := t.r.ReadByte()
+
+		if err != nil {
+			return sqlToken{}, err
+		}
+
+		switch {
+		case isSQLSpace(b):
+			continue
+		case b == '-' && t.peek('-'):
+			err = t.skipLine()
+		case b == '#':
+			err = t.skipLine()
+		case b == '/' && t.peek('*'):
+			err = t.skipBlockComment()
+		case b == '\'':
+			return t.readQuoted(b, sqlStringToken, t.backslashEscapes, pos)
+		case (b == 'E' || b == 'e') && t.peek('\''):
+			if _, err = t.r.ReadByte(); err != nil {
+				return sqlToken{}, err
+			}
+
+			return t.readQuoted('\'', sqlStringToken, true, pos)
+		case b == '"' || b == '`':
+			return t.readQuoted(b, sqlQuotedIdentifierToken, false, pos)
+		case isSQLWordByte(b):
+			return t.readWord(b, pos)
+		default:
+			return sqlToken{text: string(b), kind: sqlSymbolToken, pos: pos}, nil
+		}
+
+		if err != nil {
+			return sqlToken{}, err
+		}
+	}
+}
+
+func (t sqlTokenizer) peek(b byte) bool {
+	next, err := t.r.Peek(1)
+
+	return err == nil && next[0] == b
+}
+
+func (t sqlTokenizer) skipLine() error {
+	_, err := t.r.ReadSlice('\n')
+
+	for errors.Is(err, bufio.ErrBufferFull) {
+		_, err = t.r.ReadSlice('\n')
+	}
+
+	return err
+}
+
+func (t sqlTokenizer) skipBlockComment() error {
+	var previous byte
+
+	for {
+		b, err := t.r.ReadByte()
+
+		if err != nil {
+			return err
+		}
+
+		if previous == '*' && b == '/' {
+			return nil
+		}
+
+		previous = b
+	}
+}
+
+func (t sqlTokenizer) readWord(first byte, pos recordPosition) (sqlToken, error) {
+	var sb strings.Builder
+
+	sb.WriteByte(first)
+
+	for {
+		b, err := t.r.ReadByte()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return sqlToken{}, err
+		}
+
+		if !isSQLWordByte(b) {
+			err = t.r.UnreadByte()
+
+			if err != nil {
+				return sqlToken{}, err
+			}
+
+			break
+		}
+
+		sb.WriteByte(b)
+	}
+
+	return sqlToken{text: sb.String(), kind: sqlWordToken, pos: pos}, nil
+}
+
+// Reads a quoted string or identifier. Quotes can be escaped by doubling them, and also by backslash escapes if
+// escapes is set.
+func (t sqlTokenizer) readQuoted(quote byte, kind sqlTokenKind, escapes bool, pos recordPosition) (sqlToken, error) {
+	var sb strings.Builder
+
+	for {
+		b, err := t.r.ReadByte()
+
+		if errors.Is(err, io.EOF) {
+			return sqlToken{}, fmt.Errorf("input incorrect. Dump contains an unterminated quoted value (%c)", quote)
+		}
+
+		if err != nil {
+			return sqlToken{}, err
+		}
+
+		if b == quote {
+			if !t.peek(quote) {
+				return sqlToken{text: sb.String(), kind: kind, pos: pos}, nil
+			}
+
+			b, err = t.r.ReadByte()
+		} else if b == '\\' && escapes {
+			b, err = t.r.ReadByte()
+			b = unescapeSQLByte(b)
+		}
+
+		if err != nil {
+			return sqlToken{}, err
+		}
+
+		sb.WriteByte(b)
+	}
+}
+
+func newSQLReader(r io.Reader) *sqlReader {
+	return &sqlReader{
+		Reader: bufio.NewReader(r),
+		pos:    recordPosition{line: 1},
+	}
+}
+
+func (r *sqlReader) ReadByte() (byte, error) {
+	b, err := r.Reader.ReadByte()
+
+	if err == nil {
+		r.advance(b)
+	}
+
+	return b, err
+}
+
+func (r *sqlReader) UnreadByte() error {
+	err := r.Reader.UnreadByte()
+
+	if err == nil {
+		r.pos.offset--
+
+		if r.last == '\n' {
+			r.pos.line--
+		}
+	}
+
+	return err
+}
+
+func (r *sqlReader) ReadSlice(delim byte) ([]byte, error) {
+	line, err := r.Reader.ReadSlice(delim)
+
+	for _, b := range line {
+		r.advance(b)
+	}
+
+	return line, err
+}
+
+func (r *sqlReader) advance(b byte) {
+	r.pos.offset++
+	r.last = b
+
+	if b == '\n' {
+		r.pos.line++
+	}
+}
+
+func (tk sqlToken) isSymbol(s string) bool {
+	return tk.kind == sqlSymbolToken && tk.text == s
+}
+
+func (tk sqlToken) isKeyword(k string) bool {
+	return tk.kind == sqlWordToken && strings.EqualFold(tk.text, k)
+}
+
+func (tk sqlToken) isAnyKeyword(ks []string) bool {
+	for _, k := range ks {
+		if tk.isKeyword(k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (tk sqlToken) isIdentifier() bool {
+	return tk.kind == sqlWordToken || tk.kind == sqlQuotedIdentifierToken
+}
+
+func isSQLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\f' || b == '\v'
+}
+
+func isSQLWordByte(b byte) bool {
+	return b == '_' || b == '$' || b == '@' ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b >= 0x80
+}
+
+func unescapeSQLByte(b byte) byte {
+	switch b {
+	case '0':
+		return 0
+	case 'b':
+		return '\b'
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'Z':
+		return 26
+	default:
+		return b
+	}
+}
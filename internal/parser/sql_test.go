@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const mysqlDump = "-- MySQL dump 10.13\n" +
+	"/*!40101 SET NAMES utf8 */;\n" +
+	"DROP TABLE IF EXISTS `users`;\n" +
+	"CREATE TABLE `users` (\n" +
+	"  `id` int(11) NOT NULL AUTO_INCREMENT,\n" +
+	"  `username` varchar(255) DEFAULT NULL,\n" +
+	"  `email` varchar(255) NOT NULL,\n" +
+	"  `password` varchar(255) NOT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `idx_email` (`email`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8;\n" +
+	"CREATE TABLE `sessions` (`id` int(11), `token` varchar(255));\n" +
+	"INSERT INTO `sessions` VALUES (1,'abc'),(2,'def');\n" +
+	"INSERT INTO `users` VALUES (1,'user','test@aaa','dg''h;f'),(2,NULL,'fghj2@aaa','dg\\'),(hf'),(3,'x','fghj2','dghf');\n"
+
+const postgresDump = "CREATE TABLE public.accounts (\n" +
+	"    id integer NOT NULL,\n" +
+	"    mail character varying(255),\n" +
+	"    hash text,\n" +
+	"    CONSTRAINT accounts_pkey PRIMARY KEY (id)\n" +
+	");\n" +
+	"INSERT INTO public.accounts (id, mail, hash) VALUES (1, 'test@aaa', 'dghf');\n" +
+	"INSERT INTO public.accounts (hash, mail) VALUES ('dghf', 'fghj2@aaa');\n"
+
+func TestCanParseMySQLDump(t *testing.T) {
+	leak, err := CollectLeakParse((SQLLeakParser{}).ParseStream(strings.NewReader(mysqlDump)))
+
+	if len(leak) != 2 {
+		t.Fatalf("MySQL dump contains two valid rows in users table, but got %v users", len(leak))
+	}
+
+	if len(err) != 1 {
+		t.Fatalf("MySQL dump contains one invalid row in users table, but got %v errors: %v", len(err), err)
+	}
+}
+
+func TestCanParsePostgreSQLDumpWithInsertColumnList(t *testing.T) {
+	leak, err := CollectLeakParse((SQLLeakParser{}).ParseStream(strings.NewReader(postgresDump)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 2 {
+		t.Fatalf("PostgreSQL dump contains two valid rows in accounts table, but got %v users", len(leak))
+	}
+}
+
+func TestCanParseSQLDumpWithColumnsMappedByName(t *testing.T) {
+	dump := "INSERT INTO members (contact, secret) VALUES ('test@aaa', 'dghf');"
+
+	leak, err := CollectLeakParse((SQLLeakParser{Table: "members", EmailColumn: "contact", PasswordColumn: "secret"}).ParseStream(strings.NewReader(dump)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 1 {
+		t.Fatalf("SQL dump designated by the string below contains one valid row, but got %v users\nString: %s", len(leak), dump)
+	}
+}
+
+func TestCannotParseSQLDumpWithoutEmailAndPasswordColumns(t *testing.T) {
+	dump := "CREATE TABLE sessions (id int, token text);\nINSERT INTO sessions VALUES (1, 'abc');"
+
+	leak, err := CollectLeakParse((SQLLeakParser{}).ParseStream(strings.NewReader(dump)))
+
+	if len(leak) != 0 || len(err) != 1 {
+		t.Fatalf("SQL dump designated by the string below does not contain email and password columns, so it should contain one error\nString: %s", dump)
+	}
+}
+
+func TestCanParseSQLDumpWithUnknownTableColumnsInferredFromValues(t *testing.T) {
+	dump := "INSERT INTO users VALUES (1, 'test', 'dghf'), (2, 'test@aaa', 'dghf');\nINSERT INTO users VALUES (3, 'fghj2@aaa', 'dghf');"
+
+	leak, err := CollectLeakParse((SQLLeakParser{}).ParseStream(strings.NewReader(dump)))
+
+	if len(leak) != 2 || len(err) != 1 {
+		t.Fatalf("SQL dump designated by the string below does not declare the columns of users table, which should be inferred from its values, so it should contain two valid rows and one invalid row, but got %v users (%v)\nString: %s", len(leak), err, dump)
+	}
+}
+
+func TestCannotParseSQLDumpWithUnknownTableColumns(t *testing.T) {
+	dump := "INSERT INTO users VALUES (1, 'test', 'dghf');"
+
+	_, err := CollectLeakParse((SQLLeakParser{Table: "users"}).ParseStream(strings.NewReader(dump)))
+
+	var perr *ParseError
+
+	for _, e := range err {
+		if perr == nil {
+			errors.As(e, &perr)
+		}
+	}
+
+	if perr == nil || !strings.Contains(perr.Err.Error(), "table users") {
+		t.Fatalf("SQL dump designated by the string below does not declare the columns of users table, nor contains an email, so an error should name the table, but got %v\nString: %s", err, dump)
+	}
+}
+
+func TestCannotParseSQLDumpWithoutTablesOfKnownColumnsNamesTablesOfUnknownColumns(t *testing.T) {
+	dump := "INSERT INTO users VALUES (1, 'test', 'dghf');"
+
+	_, err := CollectLeakParse((SQLLeakParser{}).ParseStream(strings.NewReader(dump)))
+
+	if len(err) != 1 || !strings.Contains(err[0].Error(), "tables users are unknown") {
+		t.Fatalf("SQL dump designated by the string below does not declare the columns of users table, so its error should name the table, but got %v\nString: %s", err, dump)
+	}
+}
+
+func TestCanParsePostgreSQLDumpWithBackslashesInStrings(t *testing.T) {
+	dump := "SET standard_conforming_strings = on;\n" +
+		"INSERT INTO accounts (mail, hash) VALUES ('test@aaa', 'dg\\'), ('fghj2@aaa', E'dg\\'hf');\n"
+
+	records, err := SQLLeakParser{}.Preview(strings.NewReader(dump), 2)
+
+	panicOnError(err)
+
+	if len(records) != 2 || records[0].Password != "dg\\" || records[1].Password != "dg'hf" {
+		t.Fatalf("PostgreSQL dump designated by the string below does not escape backslashes, except in E'' strings, so it should contain two users with passwords dg\\ and dg'hf, but got %v\nString: %s", records, dump)
+	}
+}
+
+func TestCanParseSQLDumpOfPostgreSQLDialect(t *testing.T) {
+	dump := "INSERT INTO accounts (mail, hash) VALUES ('test@aaa', 'dg\\');\n"
+
+	records, err := SQLLeakParser{Dialect: PostgreSQLDialect}.Preview(strings.NewReader(dump), 1)
+
+	panicOnError(err)
+
+	if len(records) != 1 || records[0].Password != "dg\\" {
+		t.Fatalf("SQL dump designated by the string below is of the PostgreSQL dialect, so it should contain one user with password dg\\, but got %v\nString: %s", records, dump)
+	}
+}
+
+func TestCanParseSQLDumpWhoseTableNamesDifferInCase(t *testing.T) {
+	dump := "CREATE TABLE Users (id int, email text, password text);\nINSERT INTO users VALUES (1, 'test@aaa', 'dghf');"
+
+	leak, err := CollectLeakParse((SQLLeakParser{Table: "USERS"}).ParseStream(strings.NewReader(dump)))
+
+	panicOnErrors(err)
+
+	if len(leak) != 1 {
+		t.Fatalf("SQL dump designated by the string below declares the columns of users table, whose name is case insensitive, so it should contain one valid row, but got %v users\nString: %s", len(leak), dump)
+	}
+}
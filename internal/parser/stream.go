@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/palavrapasse/damn/pkg/entity/query"
+)
+
+// Number of goroutines that convert chunks of a leak stream concurrently.
+// Together with MaxLinesOfGoroutine it bounds how many entries are held in memory at once.
+var MaxGoroutinesOfStream = runtime.NumCPU()
+
+type LeakParseBatch struct {
+	query.LeakParse
+	Errors []error
+}
+
+// OnLeakParseBatchCallback is called with each batch of a leak as soon as it is parsed. The parse stops if it
+// returns an error.
+type OnLeakParseBatchCallback func(b LeakParseBatch) error
+
+// A LeakStreamParser reads a leak from r and hands out parsed users in batches as soon as they are ready,
+// without loading the whole leak into memory. The returned channel is closed once r has been fully consumed.
+// Error callbacks might be called concurrently.
+type LeakStreamParser interface {
+	ParseStream(r io.Reader, ecb ...OnParseErrorCallback) <-chan LeakParseBatch
+}
+
+func CollectLeakParse(batches <-chan LeakParseBatch) (query.LeakParse, []error) {
+	var errors []error
+	leak := query.LeakParse{}
+
+	for b := range batches {
+		errors = append(errors, b.Errors...)
+		leak = append(leak, b.LeakParse...)
+	}
+
+	return leak, errors
+}
+
+func errorLeakParseStream(err error, ecb ...OnParseErrorCallback) <-chan LeakParseBatch {
+	processOnParseError(err, ecb...)
+
+	batches := make(chan LeakParseBatch, 1)
+	batches <- LeakParseBatch{
+		LeakParse: query.LeakParse{},
+		Errors:    []error{err},
+	}
+
+	close(batches)
+
+	return batches
+}
+
+func streamLeakParse[T leakEntry](produce func(emit func(T)) error, convert func(T) (Credentials, error), ecb ...OnParseErrorCallback) <-chan LeakParseBatch {
+	chunks := make(chan []T, MaxGoroutinesOfStream)
+	batches := make(chan LeakParseBatch, MaxGoroutinesOfStream)
+
+	var wg sync.WaitGroup
+
+	wg.Add(MaxGoroutinesOfStream)
+
+	for i := 0; i < MaxGoroutinesOfStream; i++ {
+		go func() {
+
+			defer wg.Done()
+
+			for chunk := range chunks {
+				batches <- routineToLeakParse(chunk, convert, ecb...)
+			}
+		}()
+	}
+
+	go func() {
+		chunk := make([]T, 0, MaxLinesOfGoroutine)
+
+		err := produce(func(entry T) {
+			chunk = append(chunk, entry)
+
+			if len(chunk) == MaxLinesOfGoroutine {
+				chunks <- chunk
+				chunk = make([]T, 0, MaxLinesOfGoroutine)
+			}
+		})
+
+		if len(chunk) != 0 {
+			chunks <- chunk
+		}
+
+		close(chunks)
+
+		if err != nil {
+			processOnParseError(err, ecb...)
+
+			batches <- LeakParseBatch{
+				LeakParse: query.LeakParse{},
+				Errors:    []error{err},
+			}
+		}
+
+		wg.Wait()
+		close(batches)
+	}()
+
+	return batches
+}
+
+func routineToLeakParse[T leakEntry](entries []T, convert func(T) (Credentials, error), ecb ...OnParseErrorCallback) LeakParseBatch {
+	leak := query.LeakParse{}
+	var errors []error
+
+	for _, entry := range entries {
+
+		c, err := convert(entry)
+
+		if err == nil {
+			leak = append(leak, c.User())
+		} else {
+			perr := newParseError(entry, err)
+			processOnParseError(perr, ecb...)
+			errors = append(errors, perr)
+		}
+	}
+
+	return LeakParseBatch{
+		LeakParse: leak,
+		Errors:    errors,
+	}
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Reader that counts how many bytes were read from it.
+type countingReader struct {
+	io.Reader
+	read atomic.Int64
+}
+
+func TestParseStreamBoundsBufferedUsers(t *testing.T) {
+	defer func(n int) { MaxGoroutinesOfStream = n }(MaxGoroutinesOfStream)
+
+	MaxGoroutinesOfStream = 2
+
+	const line = "a@example.com:password\n"
+	const lines = 40 * MaxLinesOfGoroutine
+
+	r := &countingReader{Reader: strings.NewReader(strings.Repeat(line, lines))}
+
+	// Chunks that are queued for or being converted by workers, batches that are queued for or held by the
+	// consumer, and the chunk the producer is filling.
+	maxBufferedLines := (3*MaxGoroutinesOfStream+2)*MaxLinesOfGoroutine + bufio.MaxScanTokenSize/len(line)
+
+	users := 0
+
+	for b := range (PlainTextLeakParser{}).ParseStream(r) {
+		if users == 0 {
+			// The consumer is slow, so the producer should be held back instead of reading the rest of the leak.
+			time.Sleep(200 * time.Millisecond)
+
+			if read := int(r.read.Load()) / len(line); read > maxBufferedLines {
+				t.Fatalf("At most %d lines should be read ahead of a slow consumer, but %d were read", maxBufferedLines, read)
+			}
+		}
+
+		users += len(b.LeakParse)
+	}
+
+	if users != lines {
+		t.Fatalf("Every line of the leak should be parsed, but got %d users", users)
+	}
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	r.read.Add(int64(n))
+
+	return n, err
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+)
+
+// ImportMetadata is not part of the leaks database schema, so it is created by the first import that needs it. There
+// is a row for each import, since a leak can be imported in several parts.
+const createImportMetadataTableQuery = `CREATE TABLE IF NOT EXISTS ImportMetadata (
+	importid INTEGER PRIMARY KEY AUTOINCREMENT,
+	leakid INTEGER NOT NULL,
+	fingerprint TEXT NOT NULL,
+	importdatesc INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS ImportMetadataFingerprint ON ImportMetadata (fingerprint);`
+
+const findLeakQuery = `SELECT context, sharedatesc FROM Leak WHERE leakid = ?`
+
+const findImportedLeakQuery = `SELECT leakid FROM ImportMetadata WHERE fingerprint = ? ORDER BY importid LIMIT 1`
+
+const insertImportMetadataQuery = `INSERT INTO ImportMetadata (leakid, fingerprint, importdatesc) VALUES (?, ?, ?)`
+
+// Returns the id of the leak that was imported with fingerprint, or 0 if there is none.
+func findImportedLeak(tx *sql.Tx, fingerprint string) (entity.AutoGenKey, error) {
+	if _, err := tx.Exec(createImportMetadataTableQuery); err != nil {
+		return entity.AutoGenKey(0), err
+	}
+
+	var leakId int64
+
+	err := tx.QueryRow(findImportedLeakQuery, fingerprint).Scan(&leakId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.AutoGenKey(0), nil
+	}
+
+	return entity.AutoGenKey(leakId), err
+}
+
+// Returns the stored leak with leakId, or ErrLeakNotFound if there is none.
+func findLeak(tx *sql.Tx, leakId entity.AutoGenKey) (query.Leak, error) {
+	leak := query.Leak{LeakId: leakId}
+
+	err := tx.QueryRow(findLeakQuery, int64(leakId)).Scan(&leak.Context, &leak.ShareDateSC)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return leak, fmt.Errorf("could not find leak %d: %w", leakId, ErrLeakNotFound)
+	}
+
+	return leak, err
+}
+
+func insertImportMetadata(tx *sql.Tx, leakId entity.AutoGenKey, fingerprint string) error {
+	_, err := tx.Exec(insertImportMetadataQuery, int64(leakId), fingerprint, time.Now().Unix())
+
+	return err
+}
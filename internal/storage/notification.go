@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+const findLeakPlatformsQuery = `SELECT p.name FROM Platform p JOIN LeakPlatform lp ON lp.platid = p.platid
+WHERE lp.leakid = ? ORDER BY p.name`
+
+const findLeakLeakersQuery = `SELECT ba.identifier FROM BadActor ba JOIN LeakBadActor lba ON lba.baid = ba.baid
+WHERE lba.leakid = ? ORDER BY ba.identifier`
+
+// Emails without a domain are counted under the whole email, which the parser does not let through anyway.
+const countLeakDomainsQuery = `SELECT LOWER(SUBSTR(u.email, INSTR(u.email, '@') + 1)) AS domain, COUNT(*)
+FROM LeakUser lu JOIN User u ON u.userid = lu.userid WHERE lu.leakid = ? GROUP BY domain`
+
+const findLeakHashesQuery = `SELECT hu.hsha256 FROM LeakUser lu JOIN HashUser hu ON hu.userid = lu.userid
+WHERE lu.leakid = ? ORDER BY hu.hsha256`
+
+// Users of appended parts, whose ids replace the %s of the queries.
+const countAppendedDomainsQuery = `SELECT LOWER(SUBSTR(u.email, INSTR(u.email, '@') + 1)) AS domain, COUNT(*)
+FROM AppendedUser au JOIN User u ON u.userid = au.userid WHERE au.appendid IN (%s) GROUP BY domain`
+
+const findAppendedHashesQuery = `SELECT hu.hsha256 FROM AppendedUser au JOIN HashUser hu ON hu.userid = au.userid
+WHERE au.appendid IN (%s) ORDER BY hu.hsha256`
+
+// LoadNotification returns the Notification of the leak with leakId stored in the SQLite database of databasePath,
+// which describes all of its parts, or only the users of its appended part with appendId if it is not 0. The hashes
+// of the emails of its affected users are included if hashes is set.
+func LoadNotification(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, hashes bool) (importer.Notification, error) {
+	db, err := openDatabase(databasePath)
+
+	if db != nil {
+		defer db.Close()
+	}
+
+	if err != nil {
+		return importer.Notification{}, err
+	}
+
+	var appendIds []entity.AutoGenKey
+
+	if appendId != 0 {
+		appendIds = append(appendIds, appendId)
+	}
+
+	return loadNotification(ctx, db, leakId, appendIds, hashes)
+}
+
+// Loads the notification of the leak with leakId. If appendIds is not empty, its affected users are only the ones of
+// the appended parts with appendIds.
+func loadNotification(ctx context.Context, db *sql.DB, leakId entity.AutoGenKey, appendIds []entity.AutoGenKey, hashes bool) (importer.Notification, error) {
+	n := importer.Notification{
+		Version:  importer.NotificationVersion,
+		LeakId:   leakId,
+		Domains:  map[string]int{},
+		Appended: len(appendIds) != 0,
+	}
+
+	countQuery, hashesQuery, args := countLeakDomainsQuery, findLeakHashesQuery, []any{int64(leakId)}
+
+	if n.Appended {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(appendIds)), ", ")
+		countQuery = fmt.Sprintf(countAppendedDomainsQuery, placeholders)
+		hashesQuery = fmt.Sprintf(findAppendedHashesQuery, placeholders)
+		args = make([]any, len(appendIds))
+
+		for i, id := range appendIds {
+			args[i] = int64(id)
+		}
+	}
+
+	var shareDateSC int64
+
+	err := db.QueryRowContext(ctx, findLeakQuery, int64(leakId)).Scan(&n.Context, &shareDateSC)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return n, fmt.Errorf("could not find leak %d: %w", leakId, ErrLeakNotFound)
+	}
+
+	if err != nil {
+		return n, fmt.Errorf("could not look up leak %d: %w", leakId, err)
+	}
+
+	// Share dates are stored as midnight UTC, so they are formatted in UTC rather than in the local time zone.
+	n.ShareDate = time.Unix(shareDateSC, 0).UTC().Format(query.DateFormatLayout)
+
+	if n.Platforms, err = findStrings(ctx, db, findLeakPlatformsQuery, int64(leakId)); err != nil {
+		return n, fmt.Errorf("could not look up platforms of leak %d: %w", leakId, err)
+	}
+
+	if n.Leakers, err = findStrings(ctx, db, findLeakLeakersQuery, int64(leakId)); err != nil {
+		return n, fmt.Errorf("could not look up leakers of leak %d: %w", leakId, err)
+	}
+
+	if err := countDomains(ctx, db, countQuery, args, &n); err != nil {
+		return n, fmt.Errorf("could not count affected users of leak %d: %w", leakId, err)
+	}
+
+	if !hashes {
+		return n, nil
+	}
+
+	if n.Hashes, err = findStrings(ctx, db, hashesQuery, args...); err != nil {
+		return n, fmt.Errorf("could not look up affected users of leak %d: %w", leakId, err)
+	}
+
+	return n, nil
+}
+
+// Counts the affected users that q finds with args per email domain, and in total.
+func countDomains(ctx context.Context, db *sql.DB, q string, args []any, n *importer.Notification) error {
+	rows, err := db.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var domain string
+		var count int
+
+		if err := rows.Scan(&domain, &count); err != nil {
+			return err
+		}
+
+		n.Domains[domain] = count
+		n.AffectedUsers += count
+	}
+
+	return rows.Err()
+}
+
+// Returns the single column of the rows that q finds with args, which is never nil, so that it is encoded as an
+// empty JSON array.
+func findStrings(ctx context.Context, db *sql.DB, q string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	values := []string{}
+
+	for rows.Next() {
+		var v string
+
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
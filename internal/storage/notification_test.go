@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+func TestLoadNotificationDescribesEveryPartOfLeak(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	leakId, err := StoreImport(context.Background(), dbPath, newTestImport(2), importer.StoreOptions{})
+
+	if err != nil {
+		panic(err)
+	}
+
+	i := newTestImport(0)
+	i.Leak.LeakId = leakId
+	i.AffectedPlatforms = append(i.AffectedPlatforms, query.Platform{Name: "another platform"})
+
+	for n := 0; n < 3; n++ {
+		email, err := query.NewEmail(fmt.Sprintf("user%d@Example.org", n))
+
+		if err != nil {
+			panic(err)
+		}
+
+		i.AffectedUsers = append(i.AffectedUsers, query.NewUser(email))
+	}
+
+	if _, err := StoreImport(context.Background(), dbPath, i, importer.StoreOptions{}); err != nil {
+		panic(err)
+	}
+
+	n, err := LoadNotification(context.Background(), dbPath, leakId, 0, false)
+
+	if err != nil {
+		t.Fatalf("Notification of leak %d should be loaded, but got %v", leakId, err)
+	}
+
+	if n.Version != importer.NotificationVersion || n.LeakId != leakId || n.Context != "context" || n.ShareDate != "2023-01-02" {
+		t.Fatalf("Notification should describe leak %d, but got %v", leakId, n)
+	}
+
+	if len(n.Platforms) != 2 || n.Platforms[0] != "another platform" || n.Platforms[1] != "platform" || len(n.Leakers) != 1 || n.Leakers[0] != "leaker" {
+		t.Fatalf("Notification should list the platforms and leakers of both parts of leak %d, but got %v and %v", leakId, n.Platforms, n.Leakers)
+	}
+
+	if n.AffectedUsers != 5 || len(n.Domains) != 2 || n.Domains["example.com"] != 2 || n.Domains["example.org"] != 3 {
+		t.Fatalf("Notification should count the affected users of each domain of leak %d, but got %d %v", leakId, n.AffectedUsers, n.Domains)
+	}
+
+	if n.Hashes != nil {
+		t.Fatalf("Notification should not carry hashes unless they are requested, but got %v", n.Hashes)
+	}
+}
+
+func TestLoadNotificationWithHashes(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	i := newTestImport(2)
+
+	leakId, err := StoreImport(context.Background(), dbPath, i, importer.StoreOptions{})
+
+	if err != nil {
+		panic(err)
+	}
+
+	n, err := LoadNotification(context.Background(), dbPath, leakId, 0, true)
+
+	if err != nil {
+		t.Fatalf("Notification of leak %d should be loaded, but got %v", leakId, err)
+	}
+
+	if len(n.Hashes) != 2 {
+		t.Fatalf("Notification should carry the hashes of both affected users, but got %v", n.Hashes)
+	}
+
+	for _, u := range i.AffectedUsers {
+		h := string(entity.NewHSHA256(string(u.Email)))
+
+		if n.Hashes[0] != h && n.Hashes[1] != h {
+			t.Fatalf("Notification should carry hash %s of %s, but got %v", h, u.Email, n.Hashes)
+		}
+	}
+}
+
+func TestLoadNotificationOfAppendedPartOnlyDescribesItsNewUsers(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	leakId, err := StoreImport(context.Background(), dbPath, newTestImport(2), importer.StoreOptions{})
+
+	if err != nil {
+		panic(err)
+	}
+
+	var appendId entity.AutoGenKey
+
+	// The part repeats both users of the leak, and adds 2 new ones.
+	i := newTestImport(4)
+	i.Leak.LeakId = leakId
+
+	opts := importer.StoreOptions{
+		NotifyTargets: []string{"https://subscribeService/notify"},
+		OnAppended:    func(id entity.AutoGenKey) { appendId = id },
+	}
+
+	if _, err := StoreImport(context.Background(), dbPath, i, opts); err != nil || appendId == 0 {
+		panic(fmt.Sprintf("part should be appended, but got part %d (%v)", appendId, err))
+	}
+
+	n, err := LoadNotification(context.Background(), dbPath, leakId, appendId, true)
+
+	if err != nil {
+		t.Fatalf("Notification of part %d of leak %d should be loaded, but got %v", appendId, leakId, err)
+	}
+
+	if !n.Appended || n.AffectedUsers != 2 || n.Domains["example.com"] != 2 || len(n.Hashes) != 2 {
+		t.Fatalf("Notification should only describe the 2 users that part %d added, but got %v", appendId, n)
+	}
+
+	for _, u := range i.AffectedUsers[2:] {
+		h := string(entity.NewHSHA256(string(u.Email)))
+
+		if n.Hashes[0] != h && n.Hashes[1] != h {
+			t.Fatalf("Notification should carry hash %s of %s, but got %v", h, u.Email, n.Hashes)
+		}
+	}
+}
+
+func TestLoadNotificationOfMissingLeak(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	if _, err := LoadNotification(context.Background(), dbPath, 7, 0, false); !errors.Is(err, ErrLeakNotFound) {
+		t.Fatalf("Notification of a leak that is not stored should not be loaded, but got %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/database"
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+)
+
+// NotificationOutbox is not part of the leaks database schema either, so it is created by the first import that
+// needs it. A notification is pending until deliveredatesc is set. Notifications of appended parts carry the appendid
+// of their part, and the ones of whole leaks carry 0.
+const createNotificationOutboxTableQuery = `CREATE TABLE IF NOT EXISTS NotificationOutbox (
+	notificationid INTEGER PRIMARY KEY AUTOINCREMENT,
+	leakid INTEGER NOT NULL,
+	appendid INTEGER NOT NULL DEFAULT 0,
+	target TEXT NOT NULL,
+	createdatesc INTEGER NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	lasterror TEXT,
+	deliveredatesc INTEGER
+);
+CREATE INDEX IF NOT EXISTS NotificationOutboxPending ON NotificationOutbox (deliveredatesc, leakid);`
+
+// Parts appended to stored leaks, and the users that each one linked to its leak, so that the notification of a part
+// is only about its own users. They are only recorded for parts that are notified.
+const createLeakAppendTablesQuery = `CREATE TABLE IF NOT EXISTS LeakAppend (
+	appendid INTEGER PRIMARY KEY AUTOINCREMENT,
+	leakid INTEGER NOT NULL,
+	appenddatesc INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS AppendedUser (
+	appendid INTEGER NOT NULL,
+	userid INTEGER NOT NULL,
+	PRIMARY KEY (appendid, userid)
+);`
+
+const insertLeakAppendQuery = `INSERT INTO LeakAppend (leakid, appenddatesc) VALUES (?, ?)`
+
+const insertAppendedUserQuery = `INSERT OR IGNORE INTO AppendedUser (appendid, userid) VALUES (?, ?)`
+
+const insertPendingNotificationQuery = `INSERT INTO NotificationOutbox (leakid, appendid, target, createdatesc) VALUES (?, ?, ?, ?)`
+
+const findPendingNotificationsQuery = `SELECT notificationid, leakid, appendid, target FROM NotificationOutbox
+WHERE deliveredatesc IS NULL ORDER BY notificationid`
+
+// Marks the notifications of a leak to a target, either the one of a part or all of them if the given appendid is 0.
+const markNotifiedQuery = `UPDATE NotificationOutbox SET deliveredatesc = ?
+WHERE leakid = ? AND target = ? AND (? = 0 OR appendid = ?) AND deliveredatesc IS NULL`
+
+const markDeliveredQuery = `UPDATE NotificationOutbox SET deliveredatesc = ?
+WHERE leakid = ? AND target = ? AND notificationid <= ? AND deliveredatesc IS NULL`
+
+const recordNotifyFailureQuery = `UPDATE NotificationOutbox SET attempts = attempts + 1, lasterror = ?
+WHERE leakid = ? AND target = ? AND notificationid <= ? AND deliveredatesc IS NULL`
+
+// Pending notifications of a stored leak to a target, up to the one with lastId, which are delivered once. They are
+// about the appended parts with appendIds, or about the whole leak if any of them is.
+type pendingNotification struct {
+	target    string
+	appendIds []entity.AutoGenKey
+	leakId    entity.AutoGenKey
+	lastId    int64
+}
+
+// Notification that was loaded for a pending notification.
+type loadedNotification struct {
+	pending      pendingNotification
+	notification importer.Notification
+}
+
+// MarkNotified marks the pending notification of the appended part with appendId of leakId to target as delivered,
+// or all of the pending notifications of leakId to target if appendId is 0, so that they are not delivered again by
+// DeliverPendingNotifications.
+func MarkNotified(ctx context.Context, databasePath string, leakId entity.AutoGenKey, appendId entity.AutoGenKey, target string) error {
+	db, err := openDatabase(databasePath)
+
+	if db != nil {
+		defer db.Close()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, createNotificationOutboxTableQuery); err != nil {
+		return fmt.Errorf("could not create notification outbox: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, markNotifiedQuery, time.Now().Unix(), int64(leakId), target, int64(appendId), int64(appendId)); err != nil {
+		return fmt.Errorf("could not mark notification of leak %d as delivered: %w", leakId, err)
+	}
+
+	return nil
+}
+
+// DeliverPendingNotifications delivers the pending notifications of the leaks stored in the SQLite database of
+// databasePath with the Notifiers that newNotifier creates for their targets with opts, and marks each one that is
+// delivered. Pending notifications of the same leak to the same target are delivered once, with the Notification
+// that LoadNotification returns for the whole leak if any of them is about the whole leak, or for the parts they are
+// about otherwise, with the hashes of their affected users if opts.Hashes is set.
+// Notifications that fail stay pending, so that they are delivered by a later call, and an
+// importer.NotificationError is returned. It stops once ctx is done.
+func DeliverPendingNotifications(ctx context.Context, databasePath string, newNotifier importer.NewNotifierFunc, opts importer.NotifierOptions) error {
+	db, err := openDatabase(databasePath)
+
+	if db != nil {
+		defer db.Close()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	pending, err := findPendingNotifications(ctx, db)
+
+	if err != nil {
+		return err
+	}
+
+	logging.Aspirador.Info(fmt.Sprintf("Delivering %d pending notifications", len(pending)))
+
+	failed := 0
+
+	var lastErr error
+
+	// Notifications of the same leak are mostly found one after the other, so only the last one loaded is kept, as
+	// it can carry the hashes of every affected user.
+	var last loadedNotification
+
+	for _, n := range pending {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("delivery of pending notifications was interrupted: %w", err)
+		}
+
+		if err := notify(ctx, db, n, &last, newNotifier, opts); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("delivery of pending notifications was interrupted: %w", err)
+			}
+
+			logging.Aspirador.Warning(fmt.Sprintf("Notification of leak %d to %s is still pending: %v", n.leakId, n.target, err))
+
+			if _, rerr := db.Exec(recordNotifyFailureQuery, err.Error(), int64(n.leakId), n.target, n.lastId); rerr != nil {
+				return fmt.Errorf("could not record failed notification of leak %d: %w", n.leakId, rerr)
+			}
+
+			failed++
+			lastErr = err
+
+			continue
+		}
+
+		if _, err := db.Exec(markDeliveredQuery, time.Now().Unix(), int64(n.leakId), n.target, n.lastId); err != nil {
+			return fmt.Errorf("could not mark notification of leak %d as delivered: %w", n.leakId, err)
+		}
+	}
+
+	if lastErr != nil {
+		return &importer.NotificationError{Err: lastErr, Failed: failed, Total: len(pending)}
+	}
+
+	logging.Aspirador.Info(fmt.Sprintf("Delivered %d pending notifications", len(pending)))
+
+	return nil
+}
+
+// Notifies the target of n, loading its notification unless it is the last one that was loaded.
+func notify(ctx context.Context, db *sql.DB, n pendingNotification, last *loadedNotification,
+	newNotifier importer.NewNotifierFunc, opts importer.NotifierOptions,
+) error {
+	if !last.pending.sameNotification(n) {
+		notification, err := loadNotification(ctx, db, n.leakId, n.appendIds, opts.Hashes)
+
+		if err != nil {
+			return err
+		}
+
+		*last = loadedNotification{pending: n, notification: notification}
+	}
+
+	notifier, err := newNotifier(n.target, opts)
+
+	if err != nil {
+		return err
+	}
+
+	return notifier.Notify(ctx, last.notification)
+}
+
+// Records a part appended to leakId, returning its id.
+func insertLeakAppend(tx *sql.Tx, leakId entity.AutoGenKey) (entity.AutoGenKey, error) {
+	if _, err := tx.Exec(createLeakAppendTablesQuery); err != nil {
+		return entity.AutoGenKey(0), err
+	}
+
+	res, err := tx.Exec(insertLeakAppendQuery, int64(leakId), time.Now().Unix())
+
+	if err != nil {
+		return entity.AutoGenKey(0), err
+	}
+
+	id, err := res.LastInsertId()
+
+	return entity.AutoGenKey(id), err
+}
+
+// Records the users of links as users of the appended part with appendId.
+func insertAppendedUsers(tx *sql.Tx, appendId entity.AutoGenKey, links []query.LeakUser) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	insert, err := tx.Prepare(insertAppendedUserQuery)
+
+	if err != nil {
+		return err
+	}
+
+	defer insert.Close()
+
+	for _, l := range links {
+		if _, err := insert.Exec(int64(appendId), int64(l.UserId)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records a pending notification of leakId to each of targets, as part of the import of the leak or of its appended
+// part with appendId if it is not 0.
+func insertPendingNotifications(tx *sql.Tx, leakId entity.AutoGenKey, appendId entity.AutoGenKey, targets []string) error {
+	if _, err := tx.Exec(createNotificationOutboxTableQuery); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+
+	for _, t := range targets {
+		if _, err := tx.Exec(insertPendingNotificationQuery, int64(leakId), int64(appendId), t, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the pending notifications of each leak to each target, in the order they were recorded.
+func findPendingNotifications(ctx context.Context, db *sql.DB) ([]pendingNotification, error) {
+	if _, err := db.ExecContext(ctx, createNotificationOutboxTableQuery); err != nil {
+		return nil, fmt.Errorf("could not create notification outbox: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, findPendingNotificationsQuery)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not look up pending notifications: %w", err)
+	}
+
+	defer rows.Close()
+
+	var pending []pendingNotification
+
+	// Index of the pending notifications of each leak to each target.
+	type key struct {
+		target string
+		leakId int64
+	}
+
+	indexes := map[key]int{}
+	whole := map[key]bool{}
+
+	for rows.Next() {
+		var id, leakId, appendId int64
+		var target string
+
+		if err := rows.Scan(&id, &leakId, &appendId, &target); err != nil {
+			return nil, err
+		}
+
+		k := key{target: target, leakId: leakId}
+		i, ok := indexes[k]
+
+		if !ok {
+			i = len(pending)
+			indexes[k] = i
+			pending = append(pending, pendingNotification{target: target, leakId: entity.AutoGenKey(leakId)})
+		}
+
+		pending[i].lastId = id
+
+		// A notification of the whole leak is about every part appended to it as well.
+		if appendId == 0 {
+			whole[k] = true
+			pending[i].appendIds = nil
+		}
+
+		if !whole[k] {
+			pending[i].appendIds = append(pending[i].appendIds, entity.AutoGenKey(appendId))
+		}
+	}
+
+	return pending, rows.Err()
+}
+
+// Whether n is about the same leak and parts as o, so that they have the same notification.
+func (n pendingNotification) sameNotification(o pendingNotification) bool {
+	if n.leakId != o.leakId || len(n.appendIds) != len(o.appendIds) {
+		return false
+	}
+
+	for i := range n.appendIds {
+		if n.appendIds[i] != o.appendIds[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func openDatabase(databasePath string) (*sql.DB, error) {
+	dbctx, err := database.NewDatabaseContext[query.Import](databasePath)
+
+	if err != nil {
+		return dbctx.DB, fmt.Errorf("could not open database connection: %w", err)
+	}
+
+	return dbctx.DB, nil
+}
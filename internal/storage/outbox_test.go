@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/importer/importertest"
+)
+
+const pendingNotifications = "NotificationOutbox WHERE deliveredatesc IS NULL"
+
+func TestStoreImportRecordsPendingNotification(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	leakId, err := StoreImport(context.Background(), dbPath, newTestImport(2), importer.StoreOptions{NotifyTargets: []string{"https://subscribeService/notify"}})
+
+	if err != nil {
+		panic(err)
+	}
+
+	if count(dbPath, pendingNotifications) != 1 {
+		t.Fatalf("Notification of leak %d should be pending once it is stored", leakId)
+	}
+
+	if err := MarkNotified(context.Background(), dbPath, leakId, 0, "https://subscribeService/notify"); err != nil {
+		t.Fatalf("Notification of leak %d should be marked as delivered, but got %v", leakId, err)
+	}
+
+	if count(dbPath, pendingNotifications) != 0 {
+		t.Fatalf("Notification of leak %d should not be pending once it is delivered", leakId)
+	}
+}
+
+func TestStoreImportDoesNotRecordNotificationOfRolledBackImport(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := importer.StoreOptions{
+		NotifyTargets: []string{"https://subscribeService/notify"},
+		OnStored:      func(users int) { cancel() },
+	}
+
+	if _, err := StoreImport(ctx, dbPath, newTestImport(2), opts); err == nil {
+		t.Fatalf("Interrupted import should not be stored")
+	}
+
+	if err := DeliverPendingNotifications(context.Background(), dbPath, importertest.NewNotifierFunc(func(n importer.Notification) error {
+		t.Fatalf("Leak %d was rolled back, so it should not be notified", n.LeakId)
+		return nil
+	}), importer.NotifierOptions{}); err != nil {
+		t.Fatalf("There should be no pending notification to deliver, but got %v", err)
+	}
+}
+
+func TestDeliverPendingNotificationsKeepsFailedOnesPending(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	opts := importer.StoreOptions{NotifyTargets: []string{"https://subscribeService/notify"}}
+
+	first, err := StoreImport(context.Background(), dbPath, newTestImport(2), opts)
+
+	if err != nil {
+		panic(err)
+	}
+
+	i := newTestImport(1)
+	i.Leak.Context = "another context"
+
+	second, err := StoreImport(context.Background(), dbPath, i, opts)
+
+	if err != nil {
+		panic(err)
+	}
+
+	var notified []entity.AutoGenKey
+
+	notify := importertest.NewNotifierFunc(func(n importer.Notification) error {
+		notified = append(notified, n.LeakId)
+
+		if n.LeakId == second {
+			return errors.New("service is down")
+		}
+
+		return nil
+	})
+
+	if err := DeliverPendingNotifications(context.Background(), dbPath, notify, importer.NotifierOptions{}); err == nil {
+		t.Fatalf("Notification of leak %d could not be delivered, so it should be reported", second)
+	}
+
+	if len(notified) != 2 || notified[0] != first || notified[1] != second {
+		t.Fatalf("Leaks %d and %d should be notified in the order they were stored, but got %v", first, second, notified)
+	}
+
+	if count(dbPath, pendingNotifications+" AND attempts = 1") != 1 {
+		t.Fatalf("Notification of leak %d should still be pending after one failed attempt", second)
+	}
+
+	notified = nil
+
+	if err := DeliverPendingNotifications(context.Background(), dbPath, importertest.NewNotifierFunc(func(n importer.Notification) error {
+		notified = append(notified, n.LeakId)
+		return nil
+	}), importer.NotifierOptions{}); err != nil || len(notified) != 1 || notified[0] != second {
+		t.Fatalf("Only leak %d should be notified again, but got %v (%v)", second, notified, err)
+	}
+}
+
+func TestDeliverPendingNotificationsDeliversAppendedPartsOnce(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	opts := importer.StoreOptions{NotifyTargets: []string{"https://subscribeService/notify"}}
+
+	leakId, err := StoreImport(context.Background(), dbPath, newTestImport(2), opts)
+
+	if err != nil {
+		panic(err)
+	}
+
+	i := newTestImport(3)
+	i.Leak.LeakId = leakId
+
+	if _, err := StoreImport(context.Background(), dbPath, i, opts); err != nil {
+		panic(err)
+	}
+
+	notified := 0
+
+	if err := DeliverPendingNotifications(context.Background(), dbPath, importertest.NewNotifierFunc(func(n importer.Notification) error {
+		notified++
+		return nil
+	}), importer.NotifierOptions{}); err != nil || notified != 1 {
+		t.Fatalf("Leak %d should be notified once for both of its parts, but was notified %d times (%v)", leakId, notified, err)
+	}
+
+	if count(dbPath, pendingNotifications) != 0 {
+		t.Fatalf("Notifications of both parts of leak %d should be delivered", leakId)
+	}
+}
+
+func TestDeliverPendingNotificationsOfAppendedPartsOnlyDescribesTheirUsers(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	const target = "https://subscribeService/notify"
+
+	var appendId entity.AutoGenKey
+
+	opts := importer.StoreOptions{
+		NotifyTargets: []string{target},
+		OnAppended:    func(id entity.AutoGenKey) { appendId = id },
+	}
+
+	leakId, err := StoreImport(context.Background(), dbPath, newTestImport(2), opts)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if err := MarkNotified(context.Background(), dbPath, leakId, 0, target); err != nil {
+		panic(err)
+	}
+
+	// The first part adds 1 user, and the second one adds 2 users and is delivered right away.
+	for _, users := range []int{3, 5} {
+		i := newTestImport(users)
+		i.Leak.LeakId = leakId
+
+		if _, err := StoreImport(context.Background(), dbPath, i, opts); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := MarkNotified(context.Background(), dbPath, leakId, appendId, target); err != nil {
+		panic(err)
+	}
+
+	if count(dbPath, pendingNotifications) != 1 {
+		t.Fatalf("Only the notification of the first appended part of leak %d should be pending", leakId)
+	}
+
+	var notified []importer.Notification
+
+	newNotifier := importertest.NewNotifierFunc(func(n importer.Notification) error {
+		notified = append(notified, n)
+		return nil
+	})
+
+	if err := DeliverPendingNotifications(context.Background(), dbPath, newNotifier, importer.NotifierOptions{}); err != nil {
+		t.Fatalf("Pending notification should be delivered, but got %v", err)
+	}
+
+	if len(notified) != 1 || !notified[0].Appended || notified[0].AffectedUsers != 1 {
+		t.Fatalf("Notification should only describe the user that the first part added to leak %d, but got %v", leakId, notified)
+	}
+}
+
+func TestDeliverPendingNotificationsKeepsUnsupportedTargetsPending(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	if _, err := StoreImport(context.Background(), dbPath, newTestImport(2), importer.StoreOptions{NotifyTargets: []string{"ftp://subscribeService"}}); err != nil {
+		panic(err)
+	}
+
+	newNotifier := func(target string, opts importer.NotifierOptions) (importer.Notifier, error) {
+		return nil, errors.New("unsupported notification target")
+	}
+
+	var nerr *importer.NotificationError
+
+	if err := DeliverPendingNotifications(context.Background(), dbPath, newNotifier, importer.NotifierOptions{}); !errors.As(err, &nerr) || nerr.Failed != 1 {
+		t.Fatalf("Notification to an unsupported target should fail, but got %v", err)
+	}
+
+	if count(dbPath, pendingNotifications) != 1 {
+		t.Fatalf("Notification to an unsupported target should stay pending")
+	}
+}
@@ -0,0 +1,387 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/palavrapasse/damn/pkg/database"
+	"github.com/palavrapasse/damn/pkg/entity"
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+	"github.com/palavrapasse/import/internal/logging"
+)
+
+var ErrLeakNotFound = errors.New("leak does not exist")
+
+// StoreImport stores i in the SQLite database of databasePath in a single transaction. Affected users are streamed
+// by opts.Users, or taken from i if it is nil, and inserted in batches of opts.BatchSize, so that only a batch of
+// them is buffered at a time. opts.OnStored is called after each batch with the number of users stored so far.
+// The transaction is rolled back if any batch fails, or if ctx is done before it is committed, so that a failed
+// import never leaves a partially stored leak behind. The fingerprint of the import is stored along with it, and an
+// importer.DuplicateImportError is returned if it was already stored, unless opts.Force is set. If the leak of i has
+// an id, its users, leakers and platforms are appended to the stored leak, skipping users that are already linked.
+// A pending notification of the leak to each of opts.NotifyTargets is recorded in the same transaction, so that it
+// can be delivered by DeliverPendingNotifications if it is not delivered right after the import. If i is appended to
+// the stored leak, the notification is only about the users that i links to it, which are recorded as well, and
+// opts.OnAppended is called with the id of the appended part.
+func StoreImport(ctx context.Context, databasePath string, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+	logging.Aspirador.Info("Starting storage of Leak")
+
+	dbctx, err := database.NewDatabaseContext[query.Import](databasePath)
+
+	if dbctx.DB != nil {
+		defer dbctx.DB.Close()
+	}
+
+	if err != nil {
+		return entity.AutoGenKey(0), fmt.Errorf("could not open database connection: %w", err)
+	}
+
+	tx, err := dbctx.DB.Begin()
+
+	if err != nil {
+		return entity.AutoGenKey(0), fmt.Errorf("could not start transaction: %w", err)
+	}
+
+	leakId, err := insertImport(ctx, tx, i, opts)
+
+	if err == nil {
+		err = interrupted(ctx)
+	}
+
+	if err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return entity.AutoGenKey(0), fmt.Errorf("error while storing data in DB %w (could not roll back: %v)", err, rerr)
+		}
+
+		logging.Aspirador.Warning("Rolled back storage of Leak")
+
+		var derr *importer.DuplicateImportError
+
+		if errors.As(err, &derr) {
+			return entity.AutoGenKey(0), err
+		}
+
+		return entity.AutoGenKey(0), fmt.Errorf("error while storing data in DB %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.AutoGenKey(0), fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	logging.Aspirador.Info("Successful storage")
+
+	return leakId, nil
+}
+
+// Inserts the leak, its leakers and platforms, then its affected users in batches, and finally its fingerprint and
+// its pending notifications.
+func insertImport(ctx context.Context, tx *sql.Tx, i query.Import, opts importer.StoreOptions) (entity.AutoGenKey, error) {
+	if len(opts.Fingerprint) != 0 {
+		importedLeakId, err := findImportedLeak(tx, opts.Fingerprint)
+
+		if err != nil {
+			return entity.AutoGenKey(0), fmt.Errorf("could not look up fingerprint of import: %w", err)
+		}
+
+		if importedLeakId != 0 && !opts.Force {
+			return entity.AutoGenKey(0), &importer.DuplicateImportError{Fingerprint: opts.Fingerprint, LeakId: importedLeakId}
+		}
+
+		if importedLeakId != 0 {
+			logging.Aspirador.Warning(fmt.Sprintf("Forcing import of leak that was already imported as leak %d", importedLeakId))
+		}
+	}
+
+	leak, err := insertLeak(tx, i.Leak)
+
+	if err != nil {
+		return entity.AutoGenKey(0), err
+	}
+
+	leakers, err := insertPrimary(tx, database.NewBadActorTable(i.Leakers))
+
+	if err != nil {
+		return leak.LeakId, err
+	}
+
+	platforms, err := insertPrimary(tx, database.NewPlatformTable(i.AffectedPlatforms))
+
+	if err != nil {
+		return leak.LeakId, err
+	}
+
+	_, err = insertForeign(tx, database.NewLeakBadActorTable(map[query.Leak][]query.BadActor{leak: leakers}))
+
+	if err != nil {
+		return leak.LeakId, err
+	}
+
+	_, err = insertForeign(tx, database.NewLeakPlatformTable(map[query.Leak][]query.Platform{leak: platforms}))
+
+	if err != nil {
+		return leak.LeakId, err
+	}
+
+	var appendId entity.AutoGenKey
+
+	// Parts are only recorded when they are notified, so that their notifications can tell their users apart.
+	if i.Leak.LeakId != 0 && len(opts.NotifyTargets) != 0 {
+		if appendId, err = insertLeakAppend(tx, leak.LeakId); err != nil {
+			return leak.LeakId, fmt.Errorf("could not record appended part of leak: %w", err)
+		}
+	}
+
+	stored, linked, err := storeUsers(ctx, tx, leak, appendId, i, opts)
+
+	if err != nil {
+		return leak.LeakId, err
+	}
+
+	if i.Leak.LeakId != 0 {
+		logging.Aspirador.Info(fmt.Sprintf("Appended %d users to leak %d (%d were already linked to it)", linked, leak.LeakId, stored-linked))
+	}
+
+	if len(opts.Fingerprint) != 0 {
+		if err := insertImportMetadata(tx, leak.LeakId, opts.Fingerprint); err != nil {
+			return leak.LeakId, fmt.Errorf("could not store fingerprint of import: %w", err)
+		}
+	}
+
+	if len(opts.NotifyTargets) != 0 {
+		if err := insertPendingNotifications(tx, leak.LeakId, appendId, opts.NotifyTargets); err != nil {
+			return leak.LeakId, fmt.Errorf("could not record pending notifications of import: %w", err)
+		}
+	}
+
+	if appendId != 0 && opts.OnAppended != nil {
+		opts.OnAppended(appendId)
+	}
+
+	return leak.LeakId, nil
+}
+
+// Inserts the affected users that opts.Users streams, or the ones of i if it is nil, in batches of opts.BatchSize
+// and links them to leak. The users that were not linked to leak yet are recorded as users of the appended part with
+// appendId, unless it is 0. Returns how many users were stored, and how many of them were not linked to leak yet.
+func storeUsers(ctx context.Context, tx *sql.Tx, leak query.Leak, appendId entity.AutoGenKey, i query.Import, opts importer.StoreOptions) (int, int, error) {
+	batchSize := opts.BatchSize
+
+	if batchSize < 1 {
+		batchSize = importer.DefaultBatchSize
+	}
+
+	users := opts.Users
+
+	if users == nil {
+		users = func(store func(users query.LeakParse) error) error {
+			return store(i.AffectedUsers)
+		}
+	}
+
+	batch := make([]query.User, 0, batchSize)
+	stored := 0
+	linked := 0
+
+	flush := func() error {
+		if err := interrupted(ctx); err != nil {
+			return err
+		}
+
+		linkedUsers, err := insertUsers(tx, leak, batch)
+
+		if err == nil && appendId != 0 {
+			err = insertAppendedUsers(tx, appendId, linkedUsers)
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not store users %d to %d: %w", stored+1, stored+len(batch), err)
+		}
+
+		stored += len(batch)
+		linked += len(linkedUsers)
+		batch = batch[:0]
+
+		if opts.OnStored != nil {
+			opts.OnStored(stored)
+		}
+
+		return nil
+	}
+
+	err := users(func(parsed query.LeakParse) error {
+		for len(parsed) != 0 {
+			n := copy(batch[len(batch):cap(batch)], parsed)
+			batch = batch[:len(batch)+n]
+			parsed = parsed[n:]
+
+			if len(batch) == batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err == nil && len(batch) != 0 {
+		err = flush()
+	}
+
+	return stored, linked, err
+}
+
+// Returns an error if ctx is done, so that the import is rolled back.
+func interrupted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		logging.Aspirador.Warning("Interrupted, rolling back storage of Leak")
+		return fmt.Errorf("import was interrupted: %w", err)
+	}
+
+	return nil
+}
+
+// Inserts the leak, or finds it if it has an id so that the import is appended to it.
+func insertLeak(tx *sql.Tx, leak query.Leak) (query.Leak, error) {
+	if leak.LeakId != 0 {
+		return findLeak(tx, leak.LeakId)
+	}
+
+	leaks, err := insertPrimary(tx, database.NewLeakTable(leak))
+
+	if err != nil {
+		return leak, err
+	}
+
+	return leaks[0], nil
+}
+
+// Inserts users and links them to leak, returning the links of the ones that were not linked to it yet.
+func insertUsers(tx *sql.Tx, leak query.Leak, users []query.User) ([]query.LeakUser, error) {
+	users, err := insertPrimary(tx, database.NewUserTable(users))
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = insertForeign(tx, database.NewHashUserTable(users))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return insertForeign(tx, database.NewLeakUserTable(map[query.Leak][]query.User{leak: users}))
+}
+
+// Inserts the records of a primary table, ignoring the ones that already exist, and returns all of them with their
+// primary key set.
+func insertPrimary[R database.Record](tx *sql.Tx, t database.PrimaryTable[R]) ([]R, error) {
+	records := make([]R, len(t.Records))
+
+	if len(t.Records) == 0 {
+		return records, nil
+	}
+
+	insert, err := t.PrepareInsertStatement(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer insert.Close()
+
+	find, err := t.PrepareFindStatement(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer find.Close()
+
+	for i, r := range t.Records {
+		res, err := insert.Exec(t.InsertValues(r)...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+			id, err := res.LastInsertId()
+
+			if err != nil {
+				return nil, err
+			}
+
+			records[i] = database.CopyWithNewKey(r, entity.AutoGenKey(id))
+
+			continue
+		}
+
+		// The record already exists, so its key is the one of the stored record.
+		id, err := findPrimaryKey(find, t.FindValues(r), len(t.Values(r)))
+
+		if err != nil {
+			return nil, err
+		}
+
+		records[i] = database.CopyWithNewKey(r, id)
+	}
+
+	return records, nil
+}
+
+func findPrimaryKey(find *sql.Stmt, values []any, columns int) (entity.AutoGenKey, error) {
+	row := make([]any, columns)
+	dest := make([]any, columns)
+
+	for i := range row {
+		dest[i] = &row[i]
+	}
+
+	if err := find.QueryRow(values...).Scan(dest...); err != nil {
+		return entity.AutoGenKey(0), err
+	}
+
+	id, ok := row[0].(int64)
+
+	if !ok {
+		return entity.AutoGenKey(0), fmt.Errorf("could not convert primary key to int64: %v", row[0])
+	}
+
+	return entity.AutoGenKey(id), nil
+}
+
+// Inserts the records of a foreign table, ignoring the ones that already exist, and returns the ones that were
+// inserted.
+func insertForeign[R database.Record](tx *sql.Tx, t database.ForeignTable[R]) ([]R, error) {
+	if len(t.Records) == 0 {
+		return nil, nil
+	}
+
+	insert, err := t.PrepareInsertStatement(tx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer insert.Close()
+
+	var inserted []R
+
+	for _, r := range t.Records {
+		res, err := insert.Exec(t.InsertValues(r)...)
+
+		if err != nil {
+			return inserted, err
+		}
+
+		if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+			inserted = append(inserted, r)
+		}
+	}
+
+	return inserted, nil
+}
@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/palavrapasse/damn/pkg/entity/query"
+	"github.com/palavrapasse/import/internal/importer"
+)
+
+const schema = `
+CREATE TABLE User (userid INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT UNIQUE NOT NULL);
+CREATE TABLE HashUser (userid INTEGER PRIMARY KEY, hsha256 TEXT UNIQUE NOT NULL);
+CREATE TABLE Leak (leakid INTEGER PRIMARY KEY AUTOINCREMENT, sharedatesc INTEGER NOT NULL, context TEXT UNIQUE NOT NULL);
+CREATE TABLE BadActor (baid INTEGER PRIMARY KEY AUTOINCREMENT, identifier TEXT UNIQUE NOT NULL);
+CREATE TABLE Platform (platid INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT UNIQUE NOT NULL);
+CREATE TABLE LeakUser (userid INTEGER, leakid INTEGER, PRIMARY KEY (userid, leakid));
+CREATE TABLE LeakBadActor (baid INTEGER, leakid INTEGER, PRIMARY KEY (baid, leakid));
+CREATE TABLE LeakPlatform (platid INTEGER, leakid INTEGER, PRIMARY KEY (platid, leakid));
+`
+
+const testFingerprint = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestStoreImportInsertsUsersInBatches(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	var stored []int
+
+	opts := importer.StoreOptions{
+		BatchSize: 2,
+		OnStored:  func(users int) { stored = append(stored, users) },
+	}
+
+	leakId, err := StoreImport(context.Background(), dbPath, newTestImport(5), opts)
+
+	if err != nil || leakId == 0 {
+		t.Fatalf("Import should be stored, but got leak %d (%v)", leakId, err)
+	}
+
+	if count(dbPath, "LeakUser") != 5 || count(dbPath, "HashUser") != 5 {
+		t.Fatalf("All 5 users should be stored and linked to the leak")
+	}
+
+	expected := []int{2, 4, 5}
+
+	if fmt.Sprint(stored) != fmt.Sprint(expected) {
+		t.Fatalf("Stored users should be reported after each batch as %v, but got %v", expected, stored)
+	}
+}
+
+func TestStoreImportBuffersStreamedUsersInBatches(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	users := newTestImport(7).AffectedUsers
+
+	var stored []int
+
+	opts := importer.StoreOptions{
+		BatchSize: 3,
+		OnStored:  func(users int) { stored = append(stored, users) },
+		Users: func(store func(users query.LeakParse) error) error {
+			// Batches of the parser do not line up with the ones that are stored.
+			for _, b := range []query.LeakParse{users[:1], users[1:5], users[5:]} {
+				if err := store(b); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	if _, err := StoreImport(context.Background(), dbPath, newTestImport(0), opts); err != nil {
+		t.Fatalf("Import with streamed users should be stored, but got %v", err)
+	}
+
+	if count(dbPath, "LeakUser") != 7 {
+		t.Fatalf("All 7 streamed users should be stored and linked to the leak")
+	}
+
+	expected := []int{3, 6, 7}
+
+	if fmt.Sprint(stored) != fmt.Sprint(expected) {
+		t.Fatalf("Streamed users should be stored in batches as %v, but got %v", expected, stored)
+	}
+}
+
+func TestStoreImportReusesExistingUsers(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	if _, err := StoreImport(context.Background(), dbPath, newTestImport(3), importer.StoreOptions{}); err != nil {
+		panic(err)
+	}
+
+	i := newTestImport(4)
+	i.Leak.Context = "another context"
+
+	if _, err := StoreImport(context.Background(), dbPath, i, importer.StoreOptions{BatchSize: 3}); err != nil {
+		t.Fatalf("Import with users that already exist should be stored, but got %v", err)
+	}
+
+	if count(dbPath, "User") != 4 || count(dbPath, "LeakUser") != 7 {
+		t.Fatalf("Existing users should be linked to the new leak instead of being inserted again")
+	}
+}
+
+func TestStoreImportRollsBackFailedImport(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	// Users of the second batch cannot be linked to the leak.
+	execute(dbPath, `CREATE TRIGGER FailLeakUser BEFORE INSERT ON LeakUser WHEN NEW.userid > 2
+		BEGIN SELECT RAISE(ABORT, 'failed'); END`)
+
+	_, err := StoreImport(context.Background(), dbPath, newTestImport(4), importer.StoreOptions{BatchSize: 2})
+
+	if err == nil {
+		t.Fatalf("Import should fail to be stored")
+	}
+
+	for _, table := range []string{"Leak", "User", "LeakUser", "Platform", "LeakPlatform", "BadActor", "LeakBadActor"} {
+		if n := count(dbPath, table); n != 0 {
+			t.Fatalf("Failed import should be rolled back, but %d rows were left in %s", n, table)
+		}
+	}
+}
+
+func TestStoreImportRollsBackInterruptedImport(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The import is interrupted after the first batch is stored.
+	opts := importer.StoreOptions{
+		BatchSize: 2,
+		OnStored:  func(users int) { cancel() },
+	}
+
+	_, err := StoreImport(ctx, dbPath, newTestImport(4), opts)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Import should be interrupted, but got %v", err)
+	}
+
+	if n := count(dbPath, "Leak") + count(dbPath, "User"); n != 0 {
+		t.Fatalf("Interrupted import should be rolled back, but %d rows were left", n)
+	}
+}
+
+func TestStoreImportRefusesDuplicateImport(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	i := newTestImport(3)
+	opts := importer.StoreOptions{Fingerprint: testFingerprint}
+
+	leakId, err := StoreImport(context.Background(), dbPath, i, opts)
+
+	if err != nil {
+		panic(err)
+	}
+
+	i.Leak.Context = "another context"
+
+	_, err = StoreImport(context.Background(), dbPath, i, opts)
+
+	var derr *importer.DuplicateImportError
+
+	if !errors.As(err, &derr) || derr.LeakId != leakId {
+		t.Fatalf("Import with the same fingerprint should be refused pointing to leak %d, but got %v", leakId, err)
+	}
+
+	if n := count(dbPath, "Leak"); n != 1 {
+		t.Fatalf("Refused import should not be stored, but there are %d leaks", n)
+	}
+}
+
+func TestStoreImportForcesDuplicateImport(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	i := newTestImport(3)
+	opts := importer.StoreOptions{Fingerprint: testFingerprint}
+
+	if _, err := StoreImport(context.Background(), dbPath, i, opts); err != nil {
+		panic(err)
+	}
+
+	i.Leak.Context = "another context"
+	opts.Force = true
+
+	if _, err := StoreImport(context.Background(), dbPath, i, opts); err != nil {
+		t.Fatalf("Forced import with the same fingerprint should be stored, but got %v", err)
+	}
+
+	if count(dbPath, "Leak") != 2 || count(dbPath, "ImportMetadata") != 2 {
+		t.Fatalf("Forced import should be stored along with its fingerprint")
+	}
+}
+
+func TestStoreImportAppendsToStoredLeak(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	leakId, err := StoreImport(context.Background(), dbPath, newTestImport(3), importer.StoreOptions{})
+
+	if err != nil {
+		panic(err)
+	}
+
+	i := newTestImport(5)
+	i.Leak = query.Leak{LeakId: leakId}
+	i.AffectedPlatforms = append(i.AffectedPlatforms, query.Platform{Name: "another platform"})
+
+	appendedLeakId, err := StoreImport(context.Background(), dbPath, i, importer.StoreOptions{BatchSize: 2})
+
+	if err != nil || appendedLeakId != leakId {
+		t.Fatalf("Import should be appended to leak %d, but got leak %d (%v)", leakId, appendedLeakId, err)
+	}
+
+	if count(dbPath, "Leak") != 1 || count(dbPath, "LeakUser") != 5 || count(dbPath, "LeakPlatform") != 2 {
+		t.Fatalf("Users and platforms should be linked to the stored leak, without linking the same user twice")
+	}
+}
+
+func TestStoreImportCannotAppendToUnknownLeak(t *testing.T) {
+	dbPath := createTestDatabase(t)
+
+	i := newTestImport(3)
+	i.Leak = query.Leak{LeakId: 42}
+
+	_, err := StoreImport(context.Background(), dbPath, i, importer.StoreOptions{})
+
+	if !errors.Is(err, ErrLeakNotFound) {
+		t.Fatalf("Leak 42 was never stored, but got %v", err)
+	}
+
+	if n := count(dbPath, "User"); n != 0 {
+		t.Fatalf("Import to unknown leak should not be stored, but %d users were left", n)
+	}
+}
+
+func newTestImport(users int) query.Import {
+	i, err := importer.NewImport(importer.Leak{
+		ShareDate: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		Path:      "leak.txt",
+		Context:   "context",
+		Platforms: []string{"platform"},
+		Leakers:   []string{"leaker"},
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	for n := 0; n < users; n++ {
+		email, err := query.NewEmail(fmt.Sprintf("user%d@example.com", n))
+
+		if err != nil {
+			panic(err)
+		}
+
+		i.AffectedUsers = append(i.AffectedUsers, query.NewUser(email))
+	}
+
+	return i
+}
+
+func createTestDatabase(t *testing.T) string {
+	dbPath := filepath.Join(t.TempDir(), "leaks.sqlite")
+	execute(dbPath, schema)
+
+	return dbPath
+}
+
+func execute(dbPath string, query string) {
+	db, err := sql.Open("sqlite3", dbPath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer db.Close()
+
+	if _, err := db.Exec(query); err != nil {
+		panic(err)
+	}
+}
+
+func count(dbPath string, table string) int {
+	db, err := sql.Open("sqlite3", dbPath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer db.Close()
+
+	var n int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		panic(err)
+	}
+
+	return n
+}
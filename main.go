@@ -1,50 +1,37 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	as "github.com/palavrapasse/aspirador/pkg"
-	"github.com/palavrapasse/damn/pkg/database"
-	"github.com/palavrapasse/damn/pkg/entity"
-	"github.com/palavrapasse/damn/pkg/entity/query"
 	"github.com/palavrapasse/import/internal/cli"
-	"github.com/palavrapasse/import/internal/http"
 	"github.com/palavrapasse/import/internal/logging"
+	"github.com/palavrapasse/import/internal/notify"
+	"github.com/palavrapasse/import/internal/storage"
 )
 
 func main() {
 
 	logging.Aspirador = as.WithClients(logging.CreateAspiradorClients())
 
-	app := cli.CreateCliApp(storeImport, http.NotifyNewLeak)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
-	if err := app.Run(os.Args); err != nil {
-		logging.Aspirador.Error(err.Error())
-		return
-	}
-}
-
-func storeImport(databasePath string, i query.Import) (entity.AutoGenKey, error) {
-	logging.Aspirador.Info("Starting storage of Leak")
-
-	dbctx, err := database.NewDatabaseContext[query.Import](databasePath)
-
-	if dbctx.DB != nil {
-		defer dbctx.DB.Close()
-	}
-
-	if err != nil {
-		return entity.AutoGenKey(0), fmt.Errorf("could not open database connection: %w", err)
-	}
+	// The first signal interrupts the import, which is rolled back before the program exits. Signals received after
+	// that are not handled, so a second one kills the program right away.
+	go func() {
+		<-ctx.Done()
+		logging.Aspirador.Warning("Interrupted, stopping import")
+		stop()
+	}()
 
-	leakId, errInsert := dbctx.Insert(i)
+	app := cli.CreateCliApp(storage.StoreImport, notify.NewNotifier, storage.MarkNotified, storage.LoadNotification,
+		storage.DeliverPendingNotifications)
 
-	if errInsert != nil {
-		return entity.AutoGenKey(0), fmt.Errorf("error while storing data in DB %w", errInsert)
+	if err := app.RunContext(ctx, os.Args); err != nil {
+		logging.Aspirador.Error(err.Error())
+		os.Exit(cli.ExitCode(err))
 	}
-
-	logging.Aspirador.Info("Successful storage")
-
-	return leakId, nil
 }
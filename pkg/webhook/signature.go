@@ -0,0 +1,138 @@
+// Package webhook signs the notifications that import posts to webhooks, and verifies them on behalf of the
+// services that receive them.
+//
+// The signature of a notification is the hex encoded HMAC-SHA256, keyed with a secret shared by import and the
+// service, of its timestamp in Unix seconds, a dot, the unique id of its delivery, a dot and its body. It is sent in
+// the X-Signature header, prefixed by "sha256=", along with the timestamp in the X-Timestamp header and the id of the
+// delivery in the X-Delivery-Id header. Services should refuse notifications whose signature does not match, or
+// whose timestamp is too old, and can ignore deliveries whose id they already handled, since the id can't be changed
+// without breaking the signature.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader  = "X-Signature"
+	TimestampHeader  = "X-Timestamp"
+	DeliveryIdHeader = "X-Delivery-Id"
+)
+
+const signaturePrefix = "sha256="
+
+// DefaultTolerance is how far from now the timestamp of a notification can be for Verify to accept it.
+const DefaultTolerance = 5 * time.Minute
+
+// DefaultMaxBodySize is the size of the largest body that VerifyRequest reads by default. Notifications that carry
+// the hashes of the emails of a large leak can be larger.
+const DefaultMaxBodySize = 64 << 20
+
+var (
+	ErrMissingSignature = errors.New("notification is not signed")
+	ErrInvalidSignature = errors.New("signature of notification does not match")
+	ErrInvalidTimestamp = errors.New("timestamp of notification is missing or invalid")
+	ErrExpiredTimestamp = errors.New("timestamp of notification is outside of the tolerance")
+	ErrBodyTooLarge     = errors.New("body of notification is too large")
+)
+
+// Sign returns the X-Signature header of body, sent at timestamp as the delivery with deliveryId, with secret.
+func Sign(secret []byte, timestamp time.Time, deliveryId string, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac(secret, strconv.FormatInt(timestamp.Unix(), 10), deliveryId, body))
+}
+
+// SignRequest sets the X-Signature, X-Timestamp and X-Delivery-Id headers of a request whose body is body, sent at
+// timestamp as the delivery with deliveryId, with secret.
+func SignRequest(r *http.Request, secret []byte, timestamp time.Time, deliveryId string, body []byte) {
+	r.Header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	r.Header.Set(DeliveryIdHeader, deliveryId)
+	r.Header.Set(SignatureHeader, Sign(secret, timestamp, deliveryId, body))
+}
+
+// Verify checks that the signature in header matches body and secret, and that the timestamp in header is within
+// tolerance of now.
+func Verify(secret []byte, header http.Header, body []byte, tolerance time.Duration) error {
+	return verify(secret, header, body, tolerance, time.Now())
+}
+
+// VerifyRequest reads the body of r and verifies it with Verify, returning it. Bodies larger than maxBodySize bytes
+// are refused with ErrBodyTooLarge without being read further. The body of r is replaced, so that it can be read
+// again.
+func VerifyRequest(r *http.Request, secret []byte, tolerance time.Duration, maxBodySize int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read notification: %w", err)
+	}
+
+	if int64(len(body)) > maxBodySize {
+		r.Body.Close()
+		return nil, ErrBodyTooLarge
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, Verify(secret, r.Header, body, tolerance)
+}
+
+// NewDeliveryId returns a random id for the X-Delivery-Id header of a notification.
+func NewDeliveryId() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate delivery id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func verify(secret []byte, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	signature := header.Get(SignatureHeader)
+
+	if len(signature) == 0 {
+		return ErrMissingSignature
+	}
+
+	timestamp := header.Get(TimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+
+	// The signature is checked before the timestamp, so that forged timestamps are not reported as expired.
+	if err != nil || !strings.HasPrefix(signature, signaturePrefix) || !hmac.Equal(expected, mac(secret, timestamp, header.Get(DeliveryIdHeader), body)) {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrExpiredTimestamp
+	}
+
+	return nil
+}
+
+func mac(secret []byte, timestamp string, deliveryId string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write([]byte(deliveryId))
+	h.Write([]byte("."))
+	h.Write(body)
+
+	return h.Sum(nil)
+}
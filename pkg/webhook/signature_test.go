@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var secret = []byte("shared secret")
+
+func TestVerifyAcceptsSignedNotification(t *testing.T) {
+	body := []byte(`{"leakId":7}`)
+	now := time.Unix(1700000000, 0)
+
+	if err := verify(secret, newSignedHeader(now, body), body, DefaultTolerance, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Signed notification should be verified, but got %v", err)
+	}
+}
+
+func TestVerifyRefusesTamperedBody(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	header := newSignedHeader(now, []byte(`{"leakId":7}`))
+
+	if err := verify(secret, header, []byte(`{"leakId":8}`), DefaultTolerance, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Notification whose body was changed should be refused, but got %v", err)
+	}
+}
+
+func TestVerifyRefusesOtherSecret(t *testing.T) {
+	body := []byte(`{"leakId":7}`)
+	now := time.Unix(1700000000, 0)
+
+	if err := verify([]byte("other secret"), newSignedHeader(now, body), body, DefaultTolerance, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Notification signed with another secret should be refused, but got %v", err)
+	}
+}
+
+func TestVerifyRefusesTamperedTimestamp(t *testing.T) {
+	body := []byte(`{"leakId":7}`)
+	now := time.Unix(1700000000, 0)
+	header := newSignedHeader(now.Add(-time.Hour), body)
+	header.Set(TimestampHeader, "1700000000")
+
+	if err := verify(secret, header, body, DefaultTolerance, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Notification whose timestamp was changed should be refused, but got %v", err)
+	}
+}
+
+func TestVerifyRefusesTamperedDeliveryId(t *testing.T) {
+	body := []byte(`{"leakId":7}`)
+	now := time.Unix(1700000000, 0)
+	header := newSignedHeader(now, body)
+	header.Set(DeliveryIdHeader, "replayed")
+
+	if err := verify(secret, header, body, DefaultTolerance, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Notification whose delivery id was changed should be refused, but got %v", err)
+	}
+}
+
+func TestVerifyRefusesExpiredTimestamp(t *testing.T) {
+	body := []byte(`{"leakId":7}`)
+	now := time.Unix(1700000000, 0)
+
+	if err := verify(secret, newSignedHeader(now.Add(-time.Hour), body), body, DefaultTolerance, now); !errors.Is(err, ErrExpiredTimestamp) {
+		t.Fatalf("Notification signed an hour ago should be refused, but got %v", err)
+	}
+}
+
+func TestVerifyRefusesUnsignedNotification(t *testing.T) {
+	if err := Verify(secret, http.Header{}, []byte(`{"leakId":7}`), DefaultTolerance); !errors.Is(err, ErrMissingSignature) {
+		t.Fatalf("Unsigned notification should be refused, but got %v", err)
+	}
+}
+
+func TestVerifyRequestKeepsBody(t *testing.T) {
+	body := []byte(`{"leakId":7}`)
+
+	r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	SignRequest(r, secret, time.Now(), "delivery", body)
+
+	verified, err := VerifyRequest(r, secret, DefaultTolerance, DefaultMaxBodySize)
+
+	if err != nil || string(verified) != string(body) {
+		t.Fatalf("Signed request should be verified, but got %s (%v)", verified, err)
+	}
+
+	if b, _ := io.ReadAll(r.Body); string(b) != string(body) {
+		t.Fatalf("Body of a verified request should be read again, but got %s", b)
+	}
+}
+
+func TestVerifyRequestRefusesBodyLargerThanMaxBodySize(t *testing.T) {
+	body := []byte(`{"leakId":7}`)
+
+	r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	SignRequest(r, secret, time.Now(), "delivery", body)
+
+	if _, err := VerifyRequest(r, secret, DefaultTolerance, int64(len(body)-1)); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Request whose body is larger than the maximum body size should be refused, but got %v", err)
+	}
+}
+
+func TestNewDeliveryIdIsUnique(t *testing.T) {
+	a, err := NewDeliveryId()
+
+	if err != nil {
+		panic(err)
+	}
+
+	b, err := NewDeliveryId()
+
+	if err != nil {
+		panic(err)
+	}
+
+	if len(a) != 32 || a == b {
+		t.Fatalf("Delivery ids should be unique, but got %s and %s", a, b)
+	}
+}
+
+func newSignedHeader(timestamp time.Time, body []byte) http.Header {
+	r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	SignRequest(r, secret, timestamp, "delivery", body)
+
+	return r.Header
+}
@@ -1,9 +0,0 @@
-# Server configuration
-server_host=0.0.0.0
-server_port=55545
-
-# LeaksDB configuration
-leaksdb_fp=/usr/share/palavrapasse/leaksdb.sqlite
-
-# Subscribe API configuration
-subscribe_notify_url=https://subscribeService/notify
\ No newline at end of file
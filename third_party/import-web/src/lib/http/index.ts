@@ -2,6 +2,27 @@ import type { Form } from "@components";
 
 const importWebApiUrl = 'http://0.0.0.0:55545';
 
+export type ImportProgress = {
+    phase: 'reading' | 'parsing' | 'storing' | 'notifying';
+    fraction: number;
+    etaSeconds: number;
+    records: number;
+    users: number;
+    usersTotal: number;
+};
+
+export type ImportJob = {
+    id: number;
+    state: 'queued' | 'running' | 'succeeded' | 'failed';
+    fileName: string;
+    progress?: ImportProgress;
+    leakId?: number;
+    records: number;
+    users: number;
+    errors: number;
+    error?: string;
+};
+
 export default function (form: Form): Promise<Response> {
     const formData = new FormData();
 
@@ -12,11 +33,23 @@ export default function (form: Form): Promise<Response> {
     formData.set('leakFile', form.leakFile);
 
     return fetch(
-        importWebApiUrl,
+        `${importWebApiUrl}/imports`,
         {
             method: 'POST',
             body: formData,
         }
     );
 
-}
\ No newline at end of file
+}
+
+export function subscribe(id: number, onUpdate: (job: ImportJob) => void): EventSource {
+    const events = new EventSource(`${importWebApiUrl}/imports/${id}/events`);
+
+    events.addEventListener('progress', (event) => onUpdate(JSON.parse(event.data)));
+    events.addEventListener('done', (event) => {
+        events.close();
+        onUpdate(JSON.parse(event.data));
+    });
+
+    return events;
+}